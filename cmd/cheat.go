@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"github.com/siliconandsolder/go-boy/pkg/cheats"
+	"github.com/siliconandsolder/go-boy/pkg/romfile"
+	"github.com/spf13/cobra"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	cheatDirFName   = "cheat-dir"
+	defaultCheatDir = "cheats"
+)
+
+var cheatCmd = &cobra.Command{
+	Use:   "cheat",
+	Short: "manage the Game Genie and GameShark codes stored for each game",
+}
+
+var cheatListCmd = &cobra.Command{
+	Use:   "list <rom>",
+	Short: "list a game's cheats",
+	Args:  cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return editCheats(cmd, args[0], func(engine *cheats.Engine) (bool, error) {
+			for i, cheat := range engine.GetCheats() {
+				state := "off"
+				if cheat.Enabled {
+					state = "on"
+				}
+				fmt.Printf("%d\t%s\t%s\t%s\n", i, state, cheat.Code, cheat.Description)
+			}
+			return false, nil
+		})
+	},
+}
+
+var cheatAddCmd = &cobra.Command{
+	Use:   "add <rom> <code> [description]",
+	Short: "add a Game Genie (ABC-DEF or ABC-DEF-GHI) or GameShark (ttvvaaaa) code",
+	Args:  cobra.RangeArgs(2, 3),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		description := ""
+		if len(args) == 3 {
+			description = args[2]
+		}
+
+		return editCheats(cmd, args[0], func(engine *cheats.Engine) (bool, error) {
+			return true, engine.Add(args[1], description, true)
+		})
+	},
+}
+
+var cheatRemoveCmd = &cobra.Command{
+	Use:   "remove <rom> <index>",
+	Short: "remove a cheat, numbered as in cheat list",
+	Args:  cobra.ExactArgs(2),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		idx, err := strconv.Atoi(args[1])
+		if err != nil {
+			return err
+		}
+
+		return editCheats(cmd, args[0], func(engine *cheats.Engine) (bool, error) {
+			return true, engine.Remove(idx)
+		})
+	},
+}
+
+var cheatEnableCmd = newCheatToggleCmd("enable", true)
+var cheatDisableCmd = newCheatToggleCmd("disable", false)
+
+func newCheatToggleCmd(name string, enabled bool) *cobra.Command {
+	return &cobra.Command{
+		Use:   name + " <rom> <index>",
+		Short: name + " a cheat, numbered as in cheat list",
+		Args:  cobra.ExactArgs(2),
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			idx, err := strconv.Atoi(args[1])
+			if err != nil {
+				return err
+			}
+
+			return editCheats(cmd, args[0], func(engine *cheats.Engine) (bool, error) {
+				return true, engine.SetEnabled(idx, enabled)
+			})
+		},
+	}
+}
+
+// editCheats loads a game's cheats, and saves them again if edit reports a change
+func editCheats(cmd *cobra.Command, romPath string, edit func(engine *cheats.Engine) (bool, error)) error {
+	_, romFileName, err := romfile.Load(romPath, "")
+	if err != nil {
+		return err
+	}
+
+	cheatDir, _ := cmd.Flags().GetString(cheatDirFName)
+	path := getCheatPath(cheatDir, romFileName)
+
+	engine := cheats.NewEngine()
+	if err := engine.Load(path); err != nil {
+		return err
+	}
+
+	changed, err := edit(engine)
+	if err != nil {
+		return err
+	}
+	if changed {
+		return engine.Save(path)
+	}
+	return nil
+}
+
+// getCheatPath names the cheat list after the ROM file, the same way battery saves are named
+func getCheatPath(cheatDir string, romFileName string) string {
+	name := strings.TrimSuffix(romFileName, filepath.Ext(romFileName))
+	return filepath.Join(cheatDir, strings.ToLower(strings.ReplaceAll(name, " ", "_"))+".json")
+}
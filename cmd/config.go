@@ -0,0 +1,286 @@
+package main
+
+import (
+	"fmt"
+	"github.com/siliconandsolder/go-boy/pkg/audio"
+	"github.com/siliconandsolder/go-boy/pkg/cartridge"
+	"github.com/siliconandsolder/go-boy/pkg/config"
+	"github.com/siliconandsolder/go-boy/pkg/controller"
+	"github.com/spf13/cobra"
+	"github.com/veandco/go-sdl2/sdl"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	configFName        = "config"
+	screenshotDirFName = "screenshot-dir"
+	turboRateFName     = "turbo-rate"
+	turboModeFName     = "turbo-mode"
+
+	defaultScreenshotDir = "screenshots"
+
+	minSampleRate = 8000
+	maxSampleRate = 192000
+	maxBufferSize = 0xFFFF // SDL counts buffer samples in 16 bits
+)
+
+// hotkey actions, as named in the config file
+const (
+	hotkeyQuit         = "quit"
+	hotkeyCheats       = "toggle-cheats"
+	hotkeyReloadCheats = "reload-cheats"
+	hotkeyDebugger     = "debugger"
+	hotkeyScreenshot   = "screenshot"
+	hotkeyPause        = "pause"
+	hotkeyFrameAdvance = "frame-advance"
+	hotkeyHardReset    = "hard-reset"
+	hotkeySoftReset    = "soft-reset"
+)
+
+var defaultHotkeys = map[string]sdl.Keycode{
+	hotkeyQuit:         sdl.K_ESCAPE,
+	hotkeyCheats:       sdl.K_F8,
+	hotkeyReloadCheats: sdl.K_F9,
+	hotkeyDebugger:     sdl.K_F10,
+	hotkeyScreenshot:   sdl.K_F12,
+	hotkeyPause:        sdl.K_p,
+	hotkeyFrameAdvance: sdl.K_n,
+	hotkeyHardReset:    sdl.K_F5,
+	hotkeySoftReset:    sdl.K_F6,
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "manage the config file",
+}
+
+var configPathCmd = &cobra.Command{
+	Use:   "path",
+	Short: "print where the config file is read from",
+	Args:  cobra.NoArgs,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := getConfigPath(cmd)
+		if err != nil {
+			return err
+		}
+		fmt.Println(path)
+		return nil
+	},
+}
+
+var configInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "write a config file with the default settings, to edit by hand",
+	Args:  cobra.NoArgs,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := getConfigPath(cmd)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("%s already exists", path)
+		}
+
+		conf := config.NewConfig()
+		for key, button := range controller.GetDefaultKeys() {
+			conf.Keys[controller.GetButtonName(button)] = sdl.GetKeyName(key)
+		}
+		for action, key := range defaultHotkeys {
+			conf.Hotkeys[action] = sdl.GetKeyName(key)
+		}
+		conf.Scale = defaultScale
+		conf.Audio.SampleRate = audio.AUDIO_FREQUENCY
+		conf.Audio.BufferSize = audio.DEFAULT_BUFFER_SIZE
+		conf.Turbo.Rate = controller.DEFAULT_TURBO_RATE
+		conf.Turbo.Mode = controller.TURBO_HOLD
+		conf.SaveDir = cartridge.DEFAULT_SAVE_DIR
+		conf.ScreenshotDir = defaultScreenshotDir
+
+		if err := conf.Save(path); err != nil {
+			return err
+		}
+		fmt.Println(path)
+		return nil
+	},
+}
+
+func getConfigPath(cmd *cobra.Command) (string, error) {
+	if path, _ := cmd.Flags().GetString(configFName); path != "" {
+		return path, nil
+	}
+	return config.GetDefaultPath()
+}
+
+// loadSettings reads the config file and picks out the settings for a ROM
+func loadSettings(cmd *cobra.Command, rom []byte) (config.Settings, error) {
+	path, err := getConfigPath(cmd)
+	if err != nil {
+		return config.Settings{}, err
+	}
+
+	conf, err := config.Load(path)
+	if err != nil {
+		return config.Settings{}, err
+	}
+
+	return conf.ForGame(cartridge.HashRom(rom)), nil
+}
+
+// getStringSetting returns a flag's value if it was given, otherwise the configured value if there is one,
+// otherwise the flag's default
+func getStringSetting(cmd *cobra.Command, flag string, configured string) string {
+	value, _ := cmd.Flags().GetString(flag)
+	if !cmd.Flags().Changed(flag) && configured != "" {
+		return configured
+	}
+	return value
+}
+
+// getKeys returns the joypad key bindings, with any the config sets replacing the defaults for those buttons.
+// A key bound to two buttons is an error, since only one of them could ever be pressed.
+func getKeys(settings config.Settings) (map[sdl.Keycode]int, error) {
+	buttonKeys := make(map[int]sdl.Keycode)
+	for key, button := range controller.GetDefaultKeys() {
+		buttonKeys[button] = key
+	}
+
+	for name, keyName := range settings.Keys {
+		button, err := controller.GetButton(name)
+		if err != nil {
+			return nil, err
+		}
+		if buttonKeys[button], err = parseKey(keyName); err != nil {
+			return nil, err
+		}
+	}
+
+	// buttons are bound in order, so a clash is reported the same way every time
+	keys := make(map[sdl.Keycode]int)
+	for button := controller.RIGHT; button <= controller.TURBO_B; button++ {
+		key, ok := buttonKeys[button]
+		if !ok {
+			continue
+		}
+		if other, ok := keys[key]; ok {
+			return nil, keyClash(key, controller.GetButtonName(other), controller.GetButtonName(button))
+		}
+		keys[key] = button
+	}
+	return keys, nil
+}
+
+// getHotkeys returns the hotkey bindings, with any the config sets replacing the defaults for those actions.
+// A hotkey can't share a key with another hotkey or with a joypad button.
+func getHotkeys(settings config.Settings) (map[sdl.Keycode]string, error) {
+	actionKeys := make(map[string]sdl.Keycode)
+	for action, key := range defaultHotkeys {
+		actionKeys[action] = key
+	}
+
+	for action, keyName := range settings.Hotkeys {
+		if _, ok := defaultHotkeys[action]; !ok {
+			return nil, fmt.Errorf("unknown hotkey %q", action)
+		}
+		key, err := parseKey(keyName)
+		if err != nil {
+			return nil, err
+		}
+		actionKeys[action] = key
+	}
+
+	keys, err := getKeys(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	actions := make([]string, 0, len(actionKeys))
+	for action := range actionKeys {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+
+	hotkeys := make(map[sdl.Keycode]string)
+	for _, action := range actions {
+		key := actionKeys[action]
+		if button, ok := keys[key]; ok {
+			return nil, keyClash(key, controller.GetButtonName(button), action)
+		}
+		if other, ok := hotkeys[key]; ok {
+			return nil, keyClash(key, other, action)
+		}
+		hotkeys[key] = action
+	}
+	return hotkeys, nil
+}
+
+func keyClash(key sdl.Keycode, first string, second string) error {
+	return fmt.Errorf("%s is bound to both %s and %s", sdl.GetKeyName(key), first, second)
+}
+
+// parseKey reads an SDL key name, such as "Z", "Return", "Right Shift" or "F8"
+func parseKey(name string) (sdl.Keycode, error) {
+	key := sdl.GetKeyFromName(name)
+	if key == sdl.K_UNKNOWN {
+		return key, fmt.Errorf("unknown key %q", name)
+	}
+	return key, nil
+}
+
+// getAudioSettings returns the configured sample rate and buffer size, or the defaults
+func getAudioSettings(settings config.Settings) (int, int, error) {
+	sampleRate, bufferSize := audio.AUDIO_FREQUENCY, audio.DEFAULT_BUFFER_SIZE
+	if settings.Audio.SampleRate != 0 {
+		sampleRate = settings.Audio.SampleRate
+	}
+	if settings.Audio.BufferSize != 0 {
+		bufferSize = settings.Audio.BufferSize
+	}
+
+	if sampleRate < minSampleRate || sampleRate > maxSampleRate {
+		return 0, 0, fmt.Errorf("audio sample rate must be between %d and %d", minSampleRate, maxSampleRate)
+	}
+	// the sound chip waits while two buffers are queued, so both have to fit in a second's worth of samples
+	if bufferSize < 1 || bufferSize > maxBufferSize || bufferSize*2 >= sampleRate {
+		return 0, 0, fmt.Errorf("audio buffer size must be between 1 and %d for a rate of %d", min(sampleRate/2-1, maxBufferSize), sampleRate)
+	}
+	return sampleRate, bufferSize, nil
+}
+
+// saveScreenshot writes a frame to a png named after the ROM and the time
+func saveScreenshot(dir string, romName string, frame []uint32, width int, height int) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i, pixel := range frame {
+		img.Set(i%width, i/width, color.RGBA{
+			R: byte(pixel >> 24),
+			G: byte(pixel >> 16 & 0xFF),
+			B: byte(pixel >> 8 & 0xFF),
+			A: 0xFF,
+		})
+	}
+
+	name := strings.TrimSuffix(romName, filepath.Ext(romName))
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.png", name, time.Now().Format("20060102-150405.000")))
+	file, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if err := png.Encode(file, img); err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/siliconandsolder/go-boy/pkg/ramsearch"
+	"os"
+)
+
+// debugger stops the emulator between frames and takes RAM search commands from stdin
+type debugger struct {
+	search     *ramsearch.Search
+	mem        ramsearch.Memory
+	input      *bufio.Scanner
+	framesLeft int // frames to run before stopping again, or runForever
+}
+
+func newDebugger(mem ramsearch.Memory) *debugger {
+	return &debugger{
+		search:     ramsearch.NewSearch(),
+		mem:        mem,
+		input:      bufio.NewScanner(os.Stdin),
+		framesLeft: runForever,
+	}
+}
+
+// open stops the emulator at the end of the current frame
+func (d *debugger) open() {
+	d.framesLeft = 0
+}
+
+// frameDone is called at the end of every frame. While the debugger is open it blocks until told to run again,
+// and returns false if told to quit.
+func (d *debugger) frameDone() bool {
+	if d.framesLeft == runForever {
+		return true
+	}
+	if d.framesLeft > 0 {
+		d.framesLeft--
+		if d.framesLeft > 0 {
+			return true
+		}
+	}
+
+	for {
+		fmt.Print("(goboy) ")
+		if !d.input.Scan() {
+			// nothing more to read, so let the game carry on
+			d.framesLeft = runForever
+			return true
+		}
+
+		frames, err := runSearchCommand(d.search, d.mem, d.input.Text(), os.Stdout)
+		if errors.Is(err, errQuit) {
+			return false
+		} else if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		} else if frames != 0 {
+			d.framesLeft = frames
+			return true
+		}
+	}
+}
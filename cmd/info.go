@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/siliconandsolder/go-boy/pkg/cartridge"
+	"github.com/siliconandsolder/go-boy/pkg/romfile"
+	"github.com/spf13/cobra"
+	"os"
+	"text/tabwriter"
+)
+
+const jsonFName = "json"
+
+var infoCmd = &cobra.Command{
+	Use:   "info <rom>",
+	Short: "show the decoded cartridge header of a rom",
+	Args:  cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entryName, _ := cmd.Flags().GetString(entryFName)
+		fileData, _, err := romfile.Load(args[0], entryName)
+		if err != nil {
+			return err
+		}
+
+		info, err := cartridge.GetInfo(fileData)
+		if err != nil {
+			return err
+		}
+
+		if asJson, _ := cmd.Flags().GetBool(jsonFName); asJson {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetEscapeHTML(false)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(info)
+		}
+
+		licenceCode := fmt.Sprintf("0x%02X", info.OldLicenceCode)
+		if info.NewLicenceCode != "" {
+			licenceCode = fmt.Sprintf("%q", info.NewLicenceCode)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "Title:\t%s\n", info.Title)
+		fmt.Fprintf(w, "Manufacturer code:\t%s\n", info.ManufacturerCode)
+		fmt.Fprintf(w, "Licensee:\t%s (%s)\n", info.Licensee, licenceCode)
+		fmt.Fprintf(w, "Destination:\t%s\n", info.Destination)
+		fmt.Fprintf(w, "CGB:\t%s\n", info.CGB)
+		fmt.Fprintf(w, "SGB:\t%t\n", info.SGB)
+		fmt.Fprintf(w, "Cartridge type:\t%s (0x%02X)\n", info.Mapper, info.CartType)
+		fmt.Fprintf(w, "ROM size:\t%d KiB, %d banks (file is %d KiB)\n", info.RomSize/1024, info.RomBanks, info.FileSize/1024)
+		fmt.Fprintf(w, "RAM size:\t%d KiB, %d banks\n", info.RamSize/1024, info.RamBanks)
+		fmt.Fprintf(w, "Version:\t%d\n", info.Version)
+		fmt.Fprintf(w, "Header checksum:\t0x%02X (%s)\n", info.HeaderChecksum, validity(info.HeaderChecksumValid))
+		fmt.Fprintf(w, "Global checksum:\t0x%04X (%s)\n", info.GlobalChecksum, validity(info.GlobalChecksumValid))
+		fmt.Fprintf(w, "Logo:\t%s\n", validity(info.LogoValid))
+		return w.Flush()
+	},
+}
+
+func validity(valid bool) string {
+	if valid {
+		return "valid"
+	}
+	return "invalid"
+}
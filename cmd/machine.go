@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"github.com/siliconandsolder/go-boy/pkg/audio"
+	"github.com/siliconandsolder/go-boy/pkg/bus"
+	"github.com/siliconandsolder/go-boy/pkg/camera"
+	"github.com/siliconandsolder/go-boy/pkg/cartridge"
+	"github.com/siliconandsolder/go-boy/pkg/cheats"
+	"github.com/siliconandsolder/go-boy/pkg/config"
+	"github.com/siliconandsolder/go-boy/pkg/controller"
+	"github.com/siliconandsolder/go-boy/pkg/cpu"
+	"github.com/siliconandsolder/go-boy/pkg/interrupts"
+	"github.com/siliconandsolder/go-boy/pkg/model"
+	"github.com/siliconandsolder/go-boy/pkg/ppu"
+	"github.com/siliconandsolder/go-boy/pkg/sgb"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+// machine is every emulated component for one power cycle. A hard reset throws it away and builds another
+// from the same ROM.
+type machine struct {
+	cart    *cartridge.Cartridge
+	ctrl    *controller.Controller
+	manager *interrupts.Manager
+	sound   *audio.SoundChip
+	bus     *bus.Bus
+	timer   *cpu.SysTimer
+	cpu     *cpu.Cpu
+	ppu     *ppu.Ppu
+	model   model.Model
+
+	// only present when emulating the Super Game Boy
+	sgb *sgb.Sgb
+}
+
+// newMachine powers on a Game Boy with the ROM inserted, set up from the command line and config file.
+// RAM starts blank until loadSave is called, which headless runs skip.
+func newMachine(cmd *cobra.Command, settings config.Settings, rom []byte, player *audio.Player, cheatEngine *cheats.Engine) (*machine, error) {
+	// a mapper set for this game in the config file takes the place of detecting one
+	mapperName := getStringSetting(cmd, mapperFName, settings.Mapper)
+	if err := cartridge.CheckMapperName(mapperName); err != nil {
+		return nil, err
+	}
+	cart := cartridge.NewCartridgeWithMapper(rom, mapperName)
+
+	switch multicart, _ := cmd.Flags().GetString(multicartFName); multicart {
+	case "on":
+		cart.SetMulticart(true)
+	case "off":
+		cart.SetMulticart(false)
+	}
+
+	if cameraPath, _ := cmd.Flags().GetString(cameraFName); cameraPath != "" {
+		source, err := camera.NewSource(cameraPath)
+		if err != nil {
+			return nil, err
+		}
+		cart.SetCameraSource(source.NextFrame)
+	}
+
+	gbModel := model.Detect(cart.IsCGB(), cart.IsSGB())
+	if modelName, _ := cmd.Flags().GetString(modelFName); modelName != "auto" {
+		var err error
+		if gbModel, err = model.Parse(modelName); err != nil {
+			return nil, err
+		}
+	}
+
+	keys, err := getKeys(settings)
+	if err != nil {
+		return nil, err
+	}
+	turboRate, _ := cmd.Flags().GetInt(turboRateFName)
+	if !cmd.Flags().Changed(turboRateFName) && settings.Turbo.Rate != 0 {
+		turboRate = settings.Turbo.Rate
+	}
+
+	ctrl := controller.NewController()
+	ctrl.SetKeys(keys)
+	if err := ctrl.SetTurbo(turboRate, getStringSetting(cmd, turboModeFName, settings.Turbo.Mode)); err != nil {
+		return nil, err
+	}
+	m := interrupts.NewManager()
+	s := audio.NewSoundChip(player)
+	b := bus.NewBus(cart, m, ctrl, s, gbModel)
+	t := cpu.NewSysTimer(b)
+	c := cpu.NewCpu(b, m, t)
+	p := ppu.NewPPU(b)
+
+	bootRomName, _ := cmd.Flags().GetString(bootRomFName)
+	if bootRomName != "" {
+		bootRom, err := os.ReadFile(bootRomName)
+		if err != nil {
+			return nil, err
+		}
+		if err := b.LoadBootRom(bootRom); err != nil {
+			return nil, err
+		}
+		c.ResetForBootRom()
+		cart.ResetForBootRom()
+	}
+
+	var sgbChip *sgb.Sgb
+	if gbModel.IsSGB() {
+		sgbChip = sgb.NewSgb()
+		b.SetSgb(sgbChip)
+		p.SetSgb(sgbChip)
+	}
+
+	if !b.IsCGB() {
+		paletteName := getStringSetting(cmd, paletteFName, settings.Palette)
+		if paletteName == "" {
+			// a CGB colourizes DMG games by itself
+			paletteName = "none"
+			if gbModel.IsCGB() {
+				paletteName = "auto"
+			}
+		}
+
+		switch paletteName {
+		case "none":
+			break
+		case "auto":
+			p.SetCompatPalette(ppu.GetCompatPalette(cart.GetTitleBytes(), cart.IsNintendoLicensed()))
+		default:
+			palette, err := ppu.GetManualCompatPalette(paletteName)
+			if err != nil {
+				return nil, err
+			}
+			p.SetCompatPalette(palette)
+		}
+	}
+
+	b.SetCheats(cheatEngine)
+
+	return &machine{
+		cart:    cart,
+		ctrl:    ctrl,
+		manager: m,
+		sound:   s,
+		bus:     b,
+		timer:   t,
+		cpu:     c,
+		ppu:     p,
+		model:   gbModel,
+		sgb:     sgbChip,
+	}, nil
+}
+
+// loadSave finds the battery save where the command line and config file say to keep it, and loads it
+func (gb *machine) loadSave(cmd *cobra.Command, settings config.Settings, romName string) error {
+	gb.cart.SetRomName(romName)
+	gb.cart.SetSaveDir(getStringSetting(cmd, saveDirFName, settings.SaveDir))
+	saveNaming, _ := cmd.Flags().GetString(saveNameFName)
+	if err := gb.cart.SetSaveNaming(saveNaming); err != nil {
+		return err
+	}
+
+	gb.cart.LoadRAMFromFile()
+	return nil
+}
+
+// saveOnExit writes the battery save however the run loop ends, including by panicking
+func (gb *machine) saveOnExit() {
+	r := recover()
+	if err := gb.cart.SaveRAMToFile(); err != nil {
+		fmt.Fprintf(os.Stderr, "could not save: %v\n", err)
+	}
+	if r != nil {
+		panic(r)
+	}
+}
@@ -3,15 +3,18 @@ package main
 import (
 	"fmt"
 	"github.com/siliconandsolder/go-boy/pkg/audio"
-	"github.com/siliconandsolder/go-boy/pkg/bus"
 	"github.com/siliconandsolder/go-boy/pkg/cartridge"
+	"github.com/siliconandsolder/go-boy/pkg/cheats"
 	"github.com/siliconandsolder/go-boy/pkg/controller"
-	"github.com/siliconandsolder/go-boy/pkg/cpu"
 	"github.com/siliconandsolder/go-boy/pkg/interrupts"
-	"github.com/siliconandsolder/go-boy/pkg/ppu"
+	"github.com/siliconandsolder/go-boy/pkg/romfile"
+	"github.com/siliconandsolder/go-boy/pkg/sgb"
 	"github.com/spf13/cobra"
 	"github.com/veandco/go-sdl2/sdl"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 const (
@@ -19,10 +22,47 @@ const (
 	gbWidth, gbHeight int32 = 160, 144
 	scaleFName              = "scale"
 	romFName                = "rom"
+	paletteFName            = "palette"
+	bootRomFName            = "bootrom"
+	modelFName              = "model"
+	multicartFName          = "multicart"
+	cameraFName             = "camera"
+	mapperFName             = "mapper"
+	entryFName              = "entry"
+	saveDirFName            = "save-dir"
+	saveNameFName           = "save-name"
+	debugFName              = "debug"
+
+	// how often battery saves are written while the game runs, if the game has changed them
+	saveFlushInterval = 5 * time.Second
+
+	// how long to sleep between checking for input while paused, in milliseconds
+	pausedPollDelay = 16
+
+	// how many frames the soft reset hotkey holds its buttons for, long enough for games that poll slowly
+	softResetFrames = 10
 )
 
+var softResetButtons = []int{controller.A_BUTTON, controller.B_BUTTON, controller.SELECT, controller.START}
+
 var romName string
 var scale int32
+var paletteName string
+var bootRomName string
+var modelName string
+var multicart string
+var cameraPath string
+var mapperName string
+var entryName string
+var saveDir string
+var saveNaming string
+var cheatDir string
+var startDebugger bool
+var patchPath string
+var configPath string
+var screenshotDir string
+var turboRate int
+var turboMode string
 
 var rootCmd = &cobra.Command{
 	Use:   "goboy",
@@ -34,70 +74,252 @@ var rootCmd = &cobra.Command{
 			panic("no rom :(") // TODO: splash screen
 		}
 
-		scale, _ := cmd.Flags().GetInt32(scaleFName)
-		var winWidth, winHeight = gbWidth * scale, gbHeight * scale
-
-		fileData, err := os.ReadFile(fileName)
+		entryName, _ := cmd.Flags().GetString(entryFName)
+		fileData, romFileName, err := romfile.Load(fileName, entryName)
 		if err != nil {
 			panic(err) // no point in continuing
 		}
 
-		cart := cartridge.NewCartridge(fileData)
+		patchPath, _ := cmd.Flags().GetString(patchFName)
+		if fileData, err = applyRomPatch(fileName, patchPath, fileData); err != nil {
+			panic(err)
+		}
+
+		settings, err := loadSettings(cmd, fileData)
+		if err != nil {
+			panic(err)
+		}
+
+		sampleRate, bufferSize, err := getAudioSettings(settings)
+		if err != nil {
+			panic(err)
+		}
+		player := audio.NewPlayer(sampleRate, bufferSize)
+		if err := player.Start(); err != nil {
+			panic(err)
+		}
+		defer func(player *audio.Player) {
+			player.Close()
+		}(player)
+
+		cheatDir, _ := cmd.Flags().GetString(cheatDirFName)
+		cheatPath := getCheatPath(cheatDir, romFileName)
+		cheatEngine := cheats.NewEngine()
+		if err := cheatEngine.Load(cheatPath); err != nil {
+			panic(err)
+		}
+
+		gb, err := newMachine(cmd, settings, fileData, player, cheatEngine)
+		if err != nil {
+			panic(err)
+		}
+		if err := gb.loadSave(cmd, settings, romFileName); err != nil {
+			panic(err)
+		}
+		// a hard reset replaces what gb points to, so this saves whichever machine is running at the end
+		defer gb.saveOnExit()
+
+		// the SGB draws a border around the game screen
+		var frameWidth, frameHeight = gbWidth, gbHeight
+		if gb.sgb != nil {
+			frameWidth, frameHeight = sgb.FRAME_WIDTH, sgb.FRAME_HEIGHT
+		}
+
+		scale, _ := cmd.Flags().GetInt32(scaleFName)
+		if !cmd.Flags().Changed(scaleFName) && settings.Scale != 0 {
+			scale = settings.Scale
+		}
+		var winWidth, winHeight = frameWidth * scale, frameHeight * scale
 
 		var window *sdl.Window
 		var renderer *sdl.Renderer
 		var texture *sdl.Texture
 
-		window, err = sdl.CreateWindow(fmt.Sprintf("GOBOY - %s", cart.Title), sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED,
+		window, err = sdl.CreateWindow(fmt.Sprintf("GOBOY - %s", gb.cart.Title), sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED,
 			winWidth, winHeight, sdl.WINDOW_SHOWN)
 		defer window.Destroy()
 
+		paused, rumbling := false, false
+		updateTitle := func() {
+			title := fmt.Sprintf("GOBOY - %s", gb.cart.Title)
+			if paused {
+				title += " [PAUSED]"
+			}
+			if rumbling {
+				title += " [RUMBLE]"
+			}
+			window.SetTitle(title)
+		}
+		setRumbleCallback := func() {
+			gb.cart.SetRumbleCallback(func(on bool) {
+				rumbling = on
+				updateTitle()
+			})
+		}
+		setRumbleCallback()
+
 		renderer, err = sdl.CreateRenderer(window, -1, sdl.RENDERER_ACCELERATED)
 		if err != nil {
 			panic(fmt.Sprintf("Failed to create renderer: %s\n", err))
 		}
 		defer renderer.Destroy()
 
-		texture, err = renderer.CreateTexture(sdl.PIXELFORMAT_RGBA8888, sdl.TEXTUREACCESS_STREAMING, gbWidth, gbHeight)
+		texture, err = renderer.CreateTexture(sdl.PIXELFORMAT_RGBA8888, sdl.TEXTUREACCESS_STREAMING, frameWidth, frameHeight)
 		if err != nil {
 			panic(err)
 		}
 		defer texture.Destroy()
 
-		player := audio.NewPlayer()
-		if err := player.Start(); err != nil {
+		hotkeys, err := getHotkeys(settings)
+		if err != nil {
 			panic(err)
 		}
-		defer func(player *audio.Player) {
-			player.Close()
-		}(player)
+		screenshotDir := getStringSetting(cmd, screenshotDirFName, settings.ScreenshotDir)
+
+		flushTicker := time.NewTicker(saveFlushInterval)
+		defer flushTicker.Stop()
 
-		ctrl := controller.NewController()
-		m := interrupts.NewManager()
-		s := audio.NewSoundChip(player)
-		b := bus.NewBus(cart, m, ctrl, s)
-		t := cpu.NewSysTimer(b)
-		c := cpu.NewCpu(b, m, t)
-		p := ppu.NewPPU(b)
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+		defer signal.Stop(signals)
 
-		cart.LoadRAMFromFile()
-		defer cart.SaveRAMToFile()
+		debug := newDebugger(gb.bus)
+		if startDebugger, _ := cmd.Flags().GetBool(debugFName); startDebugger {
+			debug.open()
+		}
 
 		var vBuffer []uint32
+		var lastFrame []uint32
+		tilt := &tiltInput{}
 
 		running := true
+		advance := false   // run one frame, then pause again
+		softResetLeft := 0 // frames left holding the soft reset buttons
+
+		hardReset := func() {
+			// write the save first, so the new cartridge starts from it
+			if err := gb.cart.SaveRAMToFile(); err != nil {
+				fmt.Fprintf(os.Stderr, "could not save: %v\n", err)
+			}
+			next, err := newMachine(cmd, settings, fileData, player, cheatEngine)
+			if err != nil {
+				panic(err)
+			}
+			if err := next.loadSave(cmd, settings, romFileName); err != nil {
+				panic(err)
+			}
+			*gb = *next
+			debug.mem = gb.bus
+			rumbling = false
+			setRumbleCallback()
+			updateTitle()
+			softResetLeft = 0
+		}
+
+		pollEvents := func() {
+			for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
+				switch t := event.(type) {
+				case *sdl.KeyboardEvent:
+					keyCode := t.Keysym.Sym
+					if action, ok := hotkeys[keyCode]; ok {
+						// holding frame advance steps frame after frame, but every other action happens once per press
+						if t.State != sdl.PRESSED || (t.Repeat != 0 && action != hotkeyFrameAdvance) {
+							break
+						}
+						switch action {
+						case hotkeyQuit:
+							running = false
+						case hotkeyCheats:
+							cheatEngine.Enabled = !cheatEngine.Enabled
+							fmt.Printf("cheats enabled: %t\n", cheatEngine.Enabled)
+						case hotkeyReloadCheats:
+							// pick up changes made with the cheat command while the game is running
+							if err := cheatEngine.Load(cheatPath); err != nil {
+								fmt.Fprintf(os.Stderr, "could not reload cheats: %v\n", err)
+							}
+						case hotkeyDebugger:
+							debug.open()
+						case hotkeyScreenshot:
+							if lastFrame == nil {
+								break
+							}
+							path, err := saveScreenshot(screenshotDir, romFileName, lastFrame, int(frameWidth), int(frameHeight))
+							if err != nil {
+								fmt.Fprintf(os.Stderr, "could not save screenshot: %v\n", err)
+							} else {
+								fmt.Printf("saved screenshot %s\n", path)
+							}
+						case hotkeyPause:
+							paused = !paused
+							updateTitle()
+						case hotkeyFrameAdvance:
+							// pauses a running game, then steps one frame per press
+							if paused {
+								advance = true
+							} else {
+								paused = true
+								updateTitle()
+							}
+						case hotkeyHardReset:
+							hardReset()
+						case hotkeySoftReset:
+							softResetLeft = softResetFrames
+						}
+					} else if t.Repeat == 0 {
+						// a held key repeats its press, which would flip toggled turbo on and off
+						gb.ctrl.CheckJoypadEvent(keyCode, t.State)
+						tilt.checkKeyEvent(keyCode, t.State)
+					}
+				case *sdl.MouseMotionEvent:
+					tilt.checkMouseEvent(t.X, t.Y, winWidth, winHeight)
+				case *sdl.QuitEvent:
+					running = false
+				default:
+					break
+				}
+			}
+		}
+
+		// checkSignals saves every so often, and stops on ctrl+c or a kill
+		checkSignals := func() {
+			select {
+			case <-flushTicker.C:
+				if err := gb.cart.FlushRAM(); err != nil {
+					fmt.Fprintf(os.Stderr, "could not save: %v\n", err)
+				}
+			case <-signals:
+				running = false
+			default:
+				break
+			}
+		}
+
 		for running {
-			cycles, err := c.Cycle()
+			if paused && !advance {
+				// keep the window responsive without running the game
+				pollEvents()
+				checkSignals()
+				sdl.Delay(pausedPollDelay)
+				continue
+			}
+
+			cycles, err := gb.cpu.Cycle()
 			if err != nil {
 				panic(err)
 			}
-			t.Cycle(cycles)
-			s.Cycle(cycles)
-			cart.UpdateCounter(cycles)
+			gb.timer.Cycle(cycles)
+			gb.sound.Cycle(cycles)
+			gb.cart.UpdateCounter(cycles)
 
-			if vBuffer, err = p.Cycle(cycles); err != nil {
+			if vBuffer, err = gb.ppu.Cycle(cycles); err != nil {
 				panic(err)
 			} else if vBuffer != nil {
+				if gb.sgb != nil {
+					vBuffer = gb.sgb.RenderFrame(vBuffer)
+				}
+				lastFrame = vBuffer
+				advance = false
+
 				pixels, _, err := texture.Lock(nil)
 				if err != nil {
 					panic(err)
@@ -124,25 +346,32 @@ var rootCmd = &cobra.Command{
 				}
 				renderer.Present()
 
-				for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
-					switch t := event.(type) {
-					case *sdl.KeyboardEvent:
-						keyCode := t.Keysym.Sym
-						if keyCode == sdl.K_ESCAPE {
-							running = false
-						} else {
-							ctrl.CheckJoypadEvent(keyCode, t.State)
-						}
-					case *sdl.QuitEvent:
-						running = false
-					default:
-						break
+				pollEvents()
+
+				// games restart themselves when they see these held together
+				if softResetLeft > 0 {
+					softResetLeft--
+					for _, button := range softResetButtons {
+						gb.ctrl.SetInput(button, softResetLeft > 0)
 					}
 				}
 
-				if ctrl.CheckForInputs() {
-					b.ToggleInterrupt(interrupts.JOYPAD)
+				gb.ctrl.UpdateTurbo()
+				if gb.ctrl.CheckForInputs() {
+					gb.bus.ToggleInterrupt(interrupts.JOYPAD)
+				}
+
+				if gb.cart.HasTilt() {
+					gb.cart.SetTilt(tilt.getTilt())
 				}
+
+				cheatEngine.ApplyRamWrites(gb.bus)
+
+				if !debug.frameDone() {
+					running = false
+				}
+
+				checkSignals()
 			}
 		}
 	},
@@ -150,7 +379,55 @@ var rootCmd = &cobra.Command{
 
 func main() {
 	rootCmd.Flags().Int32Var(&scale, scaleFName, defaultScale, "scale the window size as a multiple of the default gameboy resolution")
-	rootCmd.Flags().StringVar(&romName, romFName, "", "specify a .gb file")
+	rootCmd.Flags().StringVar(&romName, romFName, "", "specify a .gb file, or a .zip, .gz or .tar.gz archive containing one")
+	rootCmd.Flags().StringVar(&entryName, entryFName, "", "the file to load from a zip or tar archive (default the first .gb or .gbc file)")
+	rootCmd.Flags().StringVar(&patchPath, patchFName, "", "an IPS, UPS or BPS patch to apply to the rom (default a patch next to the rom with the same name)")
+	rootCmd.Flags().StringVar(&saveDir, saveDirFName, cartridge.DEFAULT_SAVE_DIR, "the directory battery saves are kept in")
+	rootCmd.Flags().StringVar(&screenshotDir, screenshotDirFName, defaultScreenshotDir, "the directory screenshots are saved in")
+	rootCmd.PersistentFlags().StringVar(&configPath, configFName, "", "the config file to read (default config.json in goboy's user config directory)")
+	rootCmd.Flags().StringVar(&saveNaming, saveNameFName, cartridge.SAVE_NAME_FILE, "name battery saves after the rom's file name, or its sha-1 hash: file or hash")
+	rootCmd.PersistentFlags().StringVar(&cheatDir, cheatDirFName, defaultCheatDir, "the directory each game's cheats are kept in")
+	rootCmd.Flags().StringVar(&bootRomName, bootRomFName, "", "run a DMG, MGB or CGB boot rom before the game")
+	rootCmd.Flags().StringVar(&paletteName, paletteFName, "", "colourize DMG games like the CGB: none, auto, or a button combination such as up, left+a, down+b (default auto on cgb and agb, otherwise none)")
+	rootCmd.Flags().StringVar(&multicart, multicartFName, "auto", "treat an MBC1 cartridge as an MBC1M multicart: on, off, or auto to detect from the ROM")
+	rootCmd.Flags().StringVar(&cameraPath, cameraFName, "", "an image, or a directory of images, for the Pocket Camera to take pictures of")
+	rootCmd.Flags().StringVar(&mapperName, mapperFName, "auto", "override the cartridge type: rom, mbc1, mbc2, mbc3, mbc5, mbc7, camera, huc1, huc3, wisdomtree, sachen1, sachen2, licheng, ntmulticart, or auto to use the header")
+	rootCmd.Flags().IntVar(&turboRate, turboRateFName, controller.DEFAULT_TURBO_RATE, "frames the turbo keys hold A or B down, and then up, for")
+	rootCmd.Flags().StringVar(&turboMode, turboModeFName, controller.TURBO_HOLD, "turbo while the turbo key is held, or toggle it on and off with each press: hold or toggle")
+	rootCmd.Flags().BoolVar(&startDebugger, debugFName, false, "start with the debugger open; F10 opens it while the game runs")
+	rootCmd.Flags().StringVar(&modelName, modelFName, "auto", "hardware to emulate: dmg0, dmg, mgb, sgb, sgb2, cgb, agb, or auto to detect from the cartridge header")
+
+	infoCmd.Flags().Bool(jsonFName, false, "print the header as json")
+	infoCmd.Flags().String(entryFName, "", "the file to read from a zip or tar archive (default the first .gb or .gbc file)")
+	rootCmd.AddCommand(infoCmd)
+
+	saveConvertCmd.Flags().String(toFName, "", "the format to write: raw or json (default whichever the input isn't)")
+	saveCmd.AddCommand(saveConvertCmd)
+	rootCmd.AddCommand(saveCmd)
+
+	cheatCmd.AddCommand(cheatListCmd, cheatAddCmd, cheatRemoveCmd, cheatEnableCmd, cheatDisableCmd)
+	rootCmd.AddCommand(cheatCmd)
+
+	ramSearchCmd.Flags().String(movieFName, "", "a movie file with the input to play back, one line per run of frames such as \"30 a+right\"")
+	ramSearchCmd.Flags().Int(limitFName, 100, "the most candidates to print, or 0 for all of them")
+	ramSearchCmd.Flags().String(patchFName, "", "an IPS, UPS or BPS patch to apply to the rom (default a patch next to the rom with the same name)")
+	ramSearchCmd.Flags().String(entryFName, "", "the file to load from a zip or tar archive (default the first .gb or .gbc file)")
+	ramSearchCmd.Flags().String(mapperFName, "auto", "override the cartridge type, as for the emulator")
+	ramSearchCmd.Flags().String(modelFName, "auto", "hardware to emulate, as for the emulator")
+	ramSearchCmd.Flags().String(bootRomFName, "", "run a boot rom before the game, as for the emulator")
+	ramSearchCmd.Flags().String(paletteFName, "", "colourize DMG games, as for the emulator")
+	ramSearchCmd.Flags().String(multicartFName, "auto", "treat an MBC1 cartridge as a multicart, as for the emulator")
+	ramSearchCmd.Flags().String(cameraFName, "", "images for the Pocket Camera, as for the emulator")
+	ramSearchCmd.Flags().Int(turboRateFName, controller.DEFAULT_TURBO_RATE, "frames turbo holds A or B down, and then up, for")
+	ramSearchCmd.Flags().String(turboModeFName, controller.TURBO_HOLD, "turbo mode, as for the emulator: hold or toggle")
+	rootCmd.AddCommand(ramSearchCmd)
+
+	patchCmd.AddCommand(patchCreateCmd)
+	rootCmd.AddCommand(patchCmd)
+
+	configCmd.AddCommand(configPathCmd, configInitCmd)
+	rootCmd.AddCommand(configCmd)
+
 	err := rootCmd.Execute()
 	if err != nil {
 		panic(err)
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"github.com/siliconandsolder/go-boy/pkg/patch"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const patchFName = "patch"
+
+var patchCmd = &cobra.Command{
+	Use:   "patch",
+	Short: "work with IPS, UPS and BPS rom patches",
+}
+
+var patchCreateCmd = &cobra.Command{
+	Use:   "create <original> <modified> <output>",
+	Short: "create an IPS or BPS patch, picked by the output's extension, that turns one rom into another",
+	Args:  cobra.ExactArgs(3),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		original, err := os.ReadFile(args[0])
+		if err != nil {
+			return err
+		}
+		modified, err := os.ReadFile(args[1])
+		if err != nil {
+			return err
+		}
+
+		format := strings.TrimPrefix(filepath.Ext(args[2]), ".")
+		data, err := patch.Create(format, original, modified)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(args[2], data, 0644)
+	},
+}
+
+// applyRomPatch patches a freshly loaded rom with patchPath, or with a patch next to the rom with the same name
+// if patchPath is empty. Without either, the rom is returned untouched.
+func applyRomPatch(romPath string, patchPath string, rom []byte) ([]byte, error) {
+	if patchPath == "" {
+		if patchPath = patch.FindPatch(romPath); patchPath == "" {
+			return rom, nil
+		}
+	}
+
+	data, err := os.ReadFile(patchPath)
+	if err != nil {
+		return nil, err
+	}
+
+	patched, err := patch.Apply(rom, data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", patchPath, err)
+	}
+	// stderr, so the message stays out of json printed by commands like ramsearch
+	fmt.Fprintf(os.Stderr, "applied patch %s\n", patchPath)
+	return patched, nil
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/siliconandsolder/go-boy/pkg/audio"
+	"github.com/siliconandsolder/go-boy/pkg/cheats"
+	"github.com/siliconandsolder/go-boy/pkg/interrupts"
+	"github.com/siliconandsolder/go-boy/pkg/movie"
+	"github.com/siliconandsolder/go-boy/pkg/ramsearch"
+	"github.com/siliconandsolder/go-boy/pkg/romfile"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	movieFName = "movie"
+	limitFName = "limit"
+
+	// a frame is this long whether or not the LCD is on to draw it
+	cyclesPerFrame = 70224
+
+	// run freely until the debugger is opened again
+	runForever = -1
+)
+
+var errQuit = errors.New("quit")
+
+const searchHelp = `commands:
+  snap                 snapshot RAM and make every address a candidate
+  filter CMP [VALUE]   keep candidates where the value is eq, ne, gt or lt the last snapshot, or VALUE;
+                       changed and unchanged are short for ne and eq
+  size 8|16            search 8 or 16-bit values
+  endian le|be         byte order of 16-bit values
+  count                print how many candidates are left
+  list [N]             print up to N candidates (default 20)
+  run [N]              run N frames (default 1), then stop again
+  continue             run until the debugger is opened again
+  quit                 stop the emulator`
+
+// runSearchCommand runs one RAM search command from the debugger or a search script.
+// It returns how many frames to run before the next command, or runForever.
+func runSearchCommand(search *ramsearch.Search, mem ramsearch.Memory, line string, out io.Writer) (int, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+		return 0, nil
+	}
+
+	cmd, args := strings.ToLower(fields[0]), fields[1:]
+	switch cmd {
+	case "snap":
+		search.Reset(mem)
+		fmt.Fprintf(out, "%d candidates\n", search.Count())
+	case "filter":
+		filter, err := ramsearch.ParseFilter(args)
+		if err != nil {
+			return 0, err
+		}
+		if err := search.Filter(mem, filter); err != nil {
+			return 0, err
+		}
+		fmt.Fprintf(out, "%d candidates\n", search.Count())
+	case "size":
+		if len(args) != 1 {
+			return 0, fmt.Errorf("usage: size 8|16")
+		}
+		bits, err := strconv.Atoi(args[0])
+		if err != nil {
+			return 0, fmt.Errorf("invalid size %q", args[0])
+		}
+		return 0, search.SetSize(bits)
+	case "endian":
+		if len(args) != 1 || (args[0] != "le" && args[0] != "be") {
+			return 0, fmt.Errorf("usage: endian le|be")
+		}
+		search.BigEndian = args[0] == "be"
+	case "count":
+		fmt.Fprintf(out, "%d candidates\n", search.Count())
+	case "list":
+		limit := 20
+		if len(args) == 1 {
+			var err error
+			if limit, err = strconv.Atoi(args[0]); err != nil {
+				return 0, fmt.Errorf("invalid count %q", args[0])
+			}
+		}
+		for _, c := range search.GetCandidates(mem, limit) {
+			fmt.Fprintf(out, "%s %02X:%s  %d (was %d)\n", c.Region, c.Bank, c.Address, c.Value, c.Previous)
+		}
+	case "run":
+		frames := 1
+		if len(args) == 1 {
+			var err error
+			if frames, err = strconv.Atoi(args[0]); err != nil || frames < 1 {
+				return 0, fmt.Errorf("invalid frame count %q", args[0])
+			}
+		}
+		return frames, nil
+	case "continue":
+		return runForever, nil
+	case "quit":
+		return 0, errQuit
+	case "help":
+		fmt.Fprintln(out, searchHelp)
+	default:
+		return 0, fmt.Errorf("unknown command %q, try help", cmd)
+	}
+
+	return 0, nil
+}
+
+type searchStep struct {
+	Command    string `json:"command"`
+	Frame      int    `json:"frame"`
+	Candidates int    `json:"candidates"`
+}
+
+type searchResult struct {
+	Frames     int                   `json:"frames"`
+	Steps      []searchStep          `json:"steps"`
+	Candidates []ramsearch.Candidate `json:"candidates"`
+}
+
+var ramSearchCmd = &cobra.Command{
+	Use:   "ramsearch <rom> <script>",
+	Short: "search RAM without a window, playing back a movie, and print the candidates as json",
+	Long: "Runs the game without video or sound, feeding it input from a movie, and runs the script's RAM search commands " +
+		"between frames. The script takes the same commands as the debugger; continue runs to the end of the movie.\n\n" +
+		searchHelp,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entryName, _ := cmd.Flags().GetString(entryFName)
+		fileData, _, err := romfile.Load(args[0], entryName)
+		if err != nil {
+			return err
+		}
+
+		patchPath, _ := cmd.Flags().GetString(patchFName)
+		if fileData, err = applyRomPatch(args[0], patchPath, fileData); err != nil {
+			return err
+		}
+
+		script, err := os.Open(args[1])
+		if err != nil {
+			return err
+		}
+		defer script.Close()
+
+		moviePath, _ := cmd.Flags().GetString(movieFName)
+		inputs, err := movie.Load(moviePath)
+		if err != nil {
+			return err
+		}
+
+		settings, err := loadSettings(cmd, fileData)
+		if err != nil {
+			return err
+		}
+
+		// no cheats, so the search sees only what the game itself writes
+		gb, err := newMachine(cmd, settings, fileData, audio.NewSilentPlayer(), cheats.NewEngine())
+		if err != nil {
+			return err
+		}
+
+		frame := 0
+		runFrame := func() error {
+			inputs.Apply(frame, gb.ctrl)
+			gb.ctrl.UpdateTurbo()
+			if gb.ctrl.CheckForInputs() {
+				gb.bus.ToggleInterrupt(interrupts.JOYPAD)
+			}
+
+			for elapsed := 0; elapsed < cyclesPerFrame; {
+				cycles, err := gb.cpu.Cycle()
+				if err != nil {
+					return err
+				}
+				gb.timer.Cycle(cycles)
+				gb.sound.Cycle(cycles)
+				gb.cart.UpdateCounter(cycles)
+				elapsed += int(cycles)
+
+				if vBuffer, err := gb.ppu.Cycle(cycles); err != nil {
+					return err
+				} else if vBuffer != nil {
+					if gb.sgb != nil {
+						gb.sgb.RenderFrame(vBuffer)
+					}
+					break
+				}
+			}
+
+			frame++
+			return nil
+		}
+
+		search := ramsearch.NewSearch()
+		result := searchResult{
+			Steps: make([]searchStep, 0),
+		}
+
+		scanner := bufio.NewScanner(script)
+		lineNum := 0
+		for scanner.Scan() {
+			lineNum++
+			line := strings.TrimSpace(scanner.Text())
+			frames, err := runSearchCommand(search, gb.bus, line, io.Discard)
+			if errors.Is(err, errQuit) {
+				break
+			} else if err != nil {
+				return fmt.Errorf("%s:%d: %w", args[1], lineNum, err)
+			}
+
+			if frames == runForever {
+				frames = inputs.Length() - frame
+			}
+			for i := 0; i < frames; i++ {
+				if err := runFrame(); err != nil {
+					return err
+				}
+			}
+
+			if line != "" && !strings.HasPrefix(line, "#") {
+				result.Steps = append(result.Steps, searchStep{
+					Command:    line,
+					Frame:      frame,
+					Candidates: search.Count(),
+				})
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+
+		limit, _ := cmd.Flags().GetInt(limitFName)
+		result.Frames = frame
+		result.Candidates = search.GetCandidates(gb.bus, limit)
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	},
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"github.com/siliconandsolder/go-boy/pkg/cartridge"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+const toFName = "to"
+
+var saveCmd = &cobra.Command{
+	Use:   "save",
+	Short: "manage battery save files",
+}
+
+var saveConvertCmd = &cobra.Command{
+	Use:   "convert <input> <output>",
+	Short: "convert a save between goboy's old json format and the raw format used by other emulators",
+	Args:  cobra.ExactArgs(2),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			return err
+		}
+
+		save, isJson, err := cartridge.DecodeSave(data, -1)
+		if err != nil {
+			return err
+		}
+
+		format, _ := cmd.Flags().GetString(toFName)
+		if format == "" {
+			// default to the format the input isn't in
+			format = "json"
+			if isJson {
+				format = "raw"
+			}
+		}
+
+		var converted []byte
+		switch format {
+		case "raw":
+			converted = cartridge.EncodeRawSave(save)
+		case "json":
+			if converted, err = cartridge.EncodeJsonSave(save); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown save format: %s", format)
+		}
+
+		return os.WriteFile(args[1], converted, 0666)
+	},
+}
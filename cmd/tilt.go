@@ -0,0 +1,51 @@
+package main
+
+import "github.com/veandco/go-sdl2/sdl"
+
+// tiltInput drives the accelerometer in MBC7 cartridges. The I, J, K and L keys tilt fully in one direction
+// while held, otherwise the tilt follows the mouse's distance from the centre of the window.
+type tiltInput struct {
+	up     bool
+	down   bool
+	left   bool
+	right  bool
+	mouseX float64
+	mouseY float64
+}
+
+func (t *tiltInput) checkKeyEvent(keyCode sdl.Keycode, state uint8) {
+	pressed := state == sdl.PRESSED
+	switch keyCode {
+	case sdl.K_i:
+		t.up = pressed
+	case sdl.K_k:
+		t.down = pressed
+	case sdl.K_j:
+		t.left = pressed
+	case sdl.K_l:
+		t.right = pressed
+	}
+}
+
+func (t *tiltInput) checkMouseEvent(x int32, y int32, winWidth int32, winHeight int32) {
+	t.mouseX = float64(x-winWidth/2) / float64(winWidth/2)
+	t.mouseY = float64(y-winHeight/2) / float64(winHeight/2)
+}
+
+func (t *tiltInput) getTilt() (float64, float64) {
+	x, y := t.mouseX, t.mouseY
+	if t.left != t.right {
+		x = 1
+		if t.left {
+			x = -1
+		}
+	}
+	if t.up != t.down {
+		y = 1
+		if t.up {
+			y = -1
+		}
+	}
+
+	return x, y
+}
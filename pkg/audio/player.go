@@ -11,6 +11,8 @@ import (
 )
 
 const AUDIO_FREQUENCY = 48000
+const DEFAULT_BUFFER_SIZE = AUDIO_FREQUENCY / 60 // a frame's worth of samples
+const CPU_FREQUENCY = 4194304
 
 type stereoSample struct {
 	leftSample  byte
@@ -21,16 +23,32 @@ type Player struct {
 	channel     chan stereoSample
 	numChannels int
 	pinner      *runtime.Pinner
+	sampleRate  int
+	bufferSize  int
+
+	// silent players throw samples away, for running without an audio device
+	silent bool
 }
 
-func NewPlayer() *Player {
+// NewPlayer returns a player for the given sample rate, handing samples to SDL bufferSize at a time
+func NewPlayer(sampleRate int, bufferSize int) *Player {
 	return &Player{
-		channel:     make(chan stereoSample, AUDIO_FREQUENCY),
+		channel:     make(chan stereoSample, sampleRate),
 		numChannels: 0,
 		pinner:      new(runtime.Pinner),
+		sampleRate:  sampleRate,
+		bufferSize:  bufferSize,
+		silent:      false,
 	}
 }
 
+// NewSilentPlayer returns a player that discards every sample, so the sound chip never waits on it
+func NewSilentPlayer() *Player {
+	p := NewPlayer(AUDIO_FREQUENCY, DEFAULT_BUFFER_SIZE)
+	p.silent = true
+	return p
+}
+
 func (p *Player) Start() error {
 	if err := sdl.Init(sdl.INIT_AUDIO); err != nil {
 		return err
@@ -39,10 +57,10 @@ func (p *Player) Start() error {
 	p.pinner.Pin(&p.channel)
 
 	spec := &sdl.AudioSpec{
-		Freq:     AUDIO_FREQUENCY,
+		Freq:     int32(p.sampleRate),
 		Format:   sdl.AUDIO_U8,
 		Channels: 2,
-		Samples:  AUDIO_FREQUENCY / 60,
+		Samples:  uint16(p.bufferSize),
 		Callback: sdl.AudioCallback(C.Callback),
 		UserData: unsafe.Pointer(&p.channel),
 	}
@@ -62,6 +80,9 @@ func (p *Player) Close() {
 }
 
 func (p *Player) SendSample(sample stereoSample) {
+	if p.silent {
+		return
+	}
 	p.channel <- sample
 }
 
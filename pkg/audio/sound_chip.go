@@ -4,7 +4,6 @@ import "github.com/veandco/go-sdl2/sdl"
 
 const LENGTH_TIMER_MAX = 64
 const LENGTH_TIMER_WAVE_MAX = 256
-const CYCLES_PER_SAMPLE = 87
 const CYCLE_SEQUENCER_MAX = 8192
 
 type SoundChip struct {
@@ -16,7 +15,8 @@ type SoundChip struct {
 
 	frameSequencer    byte
 	cyclesToSequencer uint16
-	cyclesToSample    byte
+	cyclesToSample    uint16
+	cyclesPerSample   uint16
 	player            *Player
 }
 
@@ -33,7 +33,8 @@ func NewSoundChip(p *Player) *SoundChip {
 		Noise:             noiseRegister{},
 		frameSequencer:    0,
 		cyclesToSequencer: 0,
-		cyclesToSample:    CYCLES_PER_SAMPLE,
+		cyclesToSample:    uint16(CPU_FREQUENCY / p.sampleRate),
+		cyclesPerSample:   uint16(CPU_FREQUENCY / p.sampleRate),
 		player:            p,
 	}
 }
@@ -53,7 +54,7 @@ func (s *SoundChip) Cycle(cycles byte) {
 
 		s.cyclesToSample--
 		if s.cyclesToSample == 0 {
-			s.cyclesToSample = CYCLES_PER_SAMPLE
+			s.cyclesToSample = s.cyclesPerSample
 
 			var pulse1SampleL byte = 0
 			var pulse2SampleL byte = 0
@@ -109,7 +110,7 @@ func (s *SoundChip) Cycle(cycles byte) {
 				leftSample:  mixedSampleLeft,
 				rightSample: mixedSampleRight,
 			})
-			for len(s.player.channel) > AUDIO_FREQUENCY/30 { // two buffers' worth
+			for len(s.player.channel) > s.player.bufferSize*2 { // two buffers' worth
 				sdl.Delay(1)
 			}
 		}
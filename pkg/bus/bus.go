@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"github.com/siliconandsolder/go-boy/pkg/audio"
 	"github.com/siliconandsolder/go-boy/pkg/cartridge"
+	"github.com/siliconandsolder/go-boy/pkg/cheats"
 	"github.com/siliconandsolder/go-boy/pkg/controller"
 	"github.com/siliconandsolder/go-boy/pkg/interrupts"
+	"github.com/siliconandsolder/go-boy/pkg/model"
+	"github.com/siliconandsolder/go-boy/pkg/sgb"
 )
 
 const (
@@ -19,6 +22,9 @@ const (
 
 	INTERNAL_RAM_START = 0xC000
 	INTERNAL_RAM_END   = 0xDFFF
+	WRAM_BANK_START    = 0xD000
+	WRAM_BANK_SIZE     = 0x1000
+	WRAM_BANKS         = 8
 
 	CONTROLLER = 0xFF00
 
@@ -70,15 +76,32 @@ const (
 	FG_PALETTE_ONE    = 0xFF49
 	WY_ADDRESS        = 0xFF4A
 	WX_ADDRESS        = 0xFF4B
+	VRAM_BANK         = 0xFF4F
+	WRAM_BANK         = 0xFF70
 	INTERRUPT_REQUEST = 0xFF0F
 	INTERRUPT_ENABLE  = 0xFFFF
 
+	// CGB only
+	BG_PALETTE_SPEC  = 0xFF68
+	BG_PALETTE_DATA  = 0xFF69
+	OBJ_PALETTE_SPEC = 0xFF6A
+	OBJ_PALETTE_DATA = 0xFF6B
+
+	VRAM_BANK_SIZE   = 0x2000
+	PALETTE_RAM_SIZE = 64
+
 	SERIAL_TRANSFER_DATA    = 0xFF01
 	SERIAL_TRANSFER_CONTROL = 0xFF02
+
+	BOOT_ROM_DISABLE   = 0xFF50
+	DMG_BOOT_ROM_SIZE  = 0x100
+	CGB_BOOT_ROM_SIZE  = 0x900
+	CGB_BOOT_ROM_START = 0x200 // the cartridge header is visible between the two halves
 )
 
 type Bus struct {
-	cart *cartridge.Cartridge
+	cart  *cartridge.Cartridge
+	model model.Model
 
 	internalRam    []byte
 	videoRam       []byte
@@ -101,32 +124,92 @@ type Bus struct {
 	vramAccessible bool
 	oamAccessible  bool
 
+	// CGB only
+	cgbMode        bool
+	vramBank       byte
+	wramBank       byte
+	bgPaletteSpec  byte
+	bgPaletteRam   []byte
+	objPaletteSpec byte
+	objPaletteRam  []byte
+	hdma           *vramDma
+
 	manager *interrupts.Manager
 
 	soundChip *audio.SoundChip
+
+	// only present when emulating the Super Game Boy
+	sgb *sgb.Sgb
+
+	cheats *cheats.Engine
+
+	bootRom       []byte
+	bootRomMapped bool
 }
 
-func NewBus(cart *cartridge.Cartridge, manager *interrupts.Manager, c *controller.Controller, soundChip *audio.SoundChip) *Bus {
-	return &Bus{
+func NewBus(cart *cartridge.Cartridge, manager *interrupts.Manager, c *controller.Controller, soundChip *audio.SoundChip, m model.Model) *Bus {
+	bus := &Bus{
 		cart:           cart,
+		model:          m,
 		manager:        manager,
 		soundChip:      soundChip,
-		internalRam:    make([]byte, 8192),
-		videoRam:       make([]byte, 8192), // bitshift to upper bank
+		internalRam:    make([]byte, WRAM_BANK_SIZE*WRAM_BANKS),
+		videoRam:       make([]byte, VRAM_BANK_SIZE*2),
 		highRam:        make([]byte, 127),
 		oam:            make([]byte, 160),
 		dmaSource:      0,
 		controller:     c,
 		serialByte:     0,
-		lcdCtrl:        0x95,
-		lcdStat:        0x85,
+		bgPalette:      0xFC,
+		fgPaletteZero:  0xFF,
+		fgPaletteOne:   0xFF,
+		lcdCtrl:        0x91,
+		lcdStat:        m.GetInitialStat(),
 		scy:            0,
 		scx:            0,
 		wy:             0,
 		wx:             0,
 		vramAccessible: true,
 		oamAccessible:  true,
+		cgbMode:        m.IsCGB() && cart.IsCGB(),
+		vramBank:       0,
+		wramBank:       1,
+		bgPaletteSpec:  0,
+		bgPaletteRam:   make([]byte, PALETTE_RAM_SIZE),
+		objPaletteSpec: 0,
+		objPaletteRam:  make([]byte, PALETTE_RAM_SIZE),
+		hdma:           newVramDma(),
+		cheats:         nil,
+		bootRom:        nil,
+		bootRomMapped:  false,
+	}
+
+	// audio registers as the boot rom leaves them
+	bus.soundChip.SetMasterControl(0x80)
+	bus.soundChip.SetMasterVolume(0x77)
+	bus.soundChip.SetMasterPanning(0xF3)
+	if m.HasBootSound() {
+		bus.soundChip.SetPulse1LengthDuty(0xBF)
+		bus.soundChip.SetPulse1VolumeEnvelope(0xF3)
 	}
+
+	return bus
+}
+
+// LoadBootRom maps a DMG/MGB (256 bytes) or CGB (2304 bytes) boot ROM over the cartridge until FF50 is written.
+// The LCD starts switched off, since the boot ROM sets it up itself.
+func (bus *Bus) LoadBootRom(data []byte) error {
+	if len(data) != DMG_BOOT_ROM_SIZE && len(data) != CGB_BOOT_ROM_SIZE {
+		return fmt.Errorf("boot rom should be %d or %d bytes, was %d", DMG_BOOT_ROM_SIZE, CGB_BOOT_ROM_SIZE, len(data))
+	}
+
+	bus.bootRom = data
+	bus.bootRomMapped = true
+	bus.lcdCtrl = 0
+	bus.lcdStat = 0
+	clear(bus.videoRam)
+
+	return nil
 }
 
 func (bus *Bus) Write(addr uint16, value byte) {
@@ -134,6 +217,9 @@ func (bus *Bus) Write(addr uint16, value byte) {
 	switch addr {
 	case CONTROLLER:
 		bus.controller.SetButtonSelectors(value)
+		if bus.sgb != nil {
+			bus.sgb.WriteJoypad(value)
+		}
 	case INTERRUPT_REQUEST:
 		bus.manager.SetInterruptRequest(value)
 	case INTERRUPT_ENABLE:
@@ -144,6 +230,10 @@ func (bus *Bus) Write(addr uint16, value byte) {
 		if value == 0x81 {
 			fmt.Print(fmt.Sprintf("%c", bus.serialByte))
 		}
+	case BOOT_ROM_DISABLE:
+		if value&1 == 1 {
+			bus.bootRomMapped = false
+		}
 	case DMA_SOURCE:
 		bus.dmaSource = value
 	case LCD_CTRL_ADDRESS:
@@ -172,6 +262,25 @@ func (bus *Bus) Write(addr uint16, value byte) {
 		bus.soundChip.SetMasterControl(value)
 	}
 
+	if bus.cgbMode {
+		switch addr {
+		case VRAM_BANK:
+			bus.vramBank = value & 1
+		case WRAM_BANK:
+			bus.wramBank = value & 7
+		case BG_PALETTE_SPEC:
+			bus.bgPaletteSpec = value & 0xBF
+		case BG_PALETTE_DATA:
+			bus.bgPaletteSpec = writePaletteData(bus.bgPaletteRam, bus.bgPaletteSpec, value, bus.vramAccessible)
+		case OBJ_PALETTE_SPEC:
+			bus.objPaletteSpec = value & 0xBF
+		case OBJ_PALETTE_DATA:
+			bus.objPaletteSpec = writePaletteData(bus.objPaletteRam, bus.objPaletteSpec, value, bus.vramAccessible)
+		case HDMA_SOURCE_HIGH, HDMA_SOURCE_LOW, HDMA_DEST_HIGH, HDMA_DEST_LOW, HDMA_CONTROL:
+			bus.hdma.write(addr, value)
+		}
+	}
+
 	if bus.soundChip.IsOn() {
 		switch addr {
 		case GLOBAL_SOUND_PAN:
@@ -220,9 +329,9 @@ func (bus *Bus) Write(addr uint16, value byte) {
 	if addr <= CART_ROM_END || (addr >= CART_RAM_START && addr <= CART_RAM_END) { // TODO: write to ram
 		bus.cart.Write(addr, value)
 	} else if addr >= VRAM_START && addr <= VRAM_END && bus.vramAccessible {
-		bus.videoRam[addr-VRAM_START] = value
+		bus.videoRam[addr-VRAM_START+uint16(bus.vramBank)*VRAM_BANK_SIZE] = value
 	} else if addr >= INTERNAL_RAM_START && addr <= INTERNAL_RAM_END {
-		bus.internalRam[addr-INTERNAL_RAM_START] = value
+		bus.internalRam[bus.getInternalRamIndex(addr, bus.wramBank)] = value
 	} else if addr >= OAM_START && addr <= OAM_END && bus.oamAccessible {
 		bus.oam[addr-OAM_START] = value
 	} else if addr >= HIGH_RAM_START && addr <= HIGH_RAM_END {
@@ -232,8 +341,15 @@ func (bus *Bus) Write(addr uint16, value byte) {
 	}
 }
 func (bus *Bus) Read(addr uint16) byte {
+	if bus.bootRomMapped && bus.isBootRomAddr(addr) {
+		return bus.bootRom[addr]
+	}
+
 	switch addr {
 	case CONTROLLER:
+		if bus.sgb != nil {
+			return bus.sgb.ReadJoypad(bus.controller.GetJoypadValue())
+		}
 		return bus.controller.GetJoypadValue()
 	case INTERRUPT_REQUEST:
 		return bus.manager.GetInterruptRequests()
@@ -297,16 +413,41 @@ func (bus *Bus) Read(addr uint16) byte {
 		return bus.soundChip.GetNoiseControl()
 	}
 
-	if addr <= CART_ROM_END || (addr >= CART_RAM_START && addr <= CART_RAM_END) { // TODO: read from ram
+	if bus.cgbMode {
+		switch addr {
+		case VRAM_BANK:
+			return 0xFE | bus.vramBank
+		case WRAM_BANK:
+			return 0xF8 | bus.wramBank
+		case BG_PALETTE_SPEC:
+			return bus.bgPaletteSpec | 0x40
+		case BG_PALETTE_DATA:
+			return readPaletteData(bus.bgPaletteRam, bus.bgPaletteSpec, bus.vramAccessible)
+		case OBJ_PALETTE_SPEC:
+			return bus.objPaletteSpec | 0x40
+		case OBJ_PALETTE_DATA:
+			return readPaletteData(bus.objPaletteRam, bus.objPaletteSpec, bus.vramAccessible)
+		case HDMA_CONTROL:
+			return bus.hdma.read()
+		}
+	}
+
+	if addr <= CART_ROM_END {
+		val := bus.cart.Read(addr)
+		if bus.cheats != nil {
+			val = bus.cheats.ApplyRomRead(addr, val)
+		}
+		return val
+	} else if addr >= CART_RAM_START && addr <= CART_RAM_END {
 		return bus.cart.Read(addr)
 	} else if addr >= VRAM_START && addr <= VRAM_END {
 		if bus.vramAccessible {
-			return bus.videoRam[addr-VRAM_START]
+			return bus.videoRam[addr-VRAM_START+uint16(bus.vramBank)*VRAM_BANK_SIZE]
 		} else {
 			return 0xFF
 		}
 	} else if addr >= INTERNAL_RAM_START && addr <= INTERNAL_RAM_END {
-		return bus.internalRam[addr-INTERNAL_RAM_START]
+		return bus.internalRam[bus.getInternalRamIndex(addr, bus.wramBank)]
 	} else if addr >= OAM_START && addr <= OAM_END {
 		if bus.oamAccessible {
 			return bus.oam[addr-OAM_START]
@@ -322,10 +463,91 @@ func (bus *Bus) Read(addr uint16) byte {
 	return 0xFF
 }
 
+// getInternalRamIndex maps an address to work RAM. Only the CGB can switch the bank at 0xD000, and bank 0 selects bank 1.
+func (bus *Bus) getInternalRamIndex(addr uint16, bank byte) uint32 {
+	if addr < WRAM_BANK_START {
+		return uint32(addr - INTERNAL_RAM_START)
+	}
+
+	if !bus.cgbMode || bank == 0 {
+		bank = 1
+	}
+	return uint32(addr-WRAM_BANK_START) + uint32(bank&(WRAM_BANKS-1))*WRAM_BANK_SIZE
+}
+
+// SetCheats attaches the cheats applied to ROM reads
+func (bus *Bus) SetCheats(engine *cheats.Engine) {
+	bus.cheats = engine
+}
+
+// WriteBank writes to a specific bank of work RAM or cartridge RAM, whichever is currently mapped in.
+// Other addresses are written normally.
+func (bus *Bus) WriteBank(bank byte, addr uint16, value byte) {
+	if addr >= CART_RAM_START && addr <= CART_RAM_END {
+		bus.cart.WriteRamBank(bank, addr, value)
+	} else if addr >= INTERNAL_RAM_START && addr <= INTERNAL_RAM_END {
+		bus.internalRam[bus.getInternalRamIndex(addr, bank)] = value
+	} else {
+		bus.Write(addr, value)
+	}
+}
+
+// ReadBank reads from a specific bank of work RAM or cartridge RAM, whichever is currently mapped in.
+// Other addresses are read normally.
+func (bus *Bus) ReadBank(bank byte, addr uint16) byte {
+	if addr >= CART_RAM_START && addr <= CART_RAM_END {
+		return bus.cart.ReadRamBank(bank, addr)
+	} else if addr >= INTERNAL_RAM_START && addr <= INTERNAL_RAM_END {
+		return bus.internalRam[bus.getInternalRamIndex(addr, bank)]
+	}
+	return bus.Read(addr)
+}
+
+// GetCartRamSize returns the size of cartridge RAM in bytes
+func (bus *Bus) GetCartRamSize() int {
+	return bus.cart.GetRamSize()
+}
+
 func (bus *Bus) PpuReadVram(addr uint16) byte {
 	return bus.videoRam[addr-VRAM_START]
 }
 
+func (bus *Bus) isBootRomAddr(addr uint16) bool {
+	if addr < DMG_BOOT_ROM_SIZE {
+		return true
+	}
+	return len(bus.bootRom) == CGB_BOOT_ROM_SIZE && addr >= CGB_BOOT_ROM_START && addr < CGB_BOOT_ROM_SIZE
+}
+
+// PpuReadVramBank reads from either VRAM bank regardless of VBK. Bank 1 only exists on the CGB.
+func (bus *Bus) PpuReadVramBank(addr uint16, bank byte) byte {
+	return bus.videoRam[addr-VRAM_START+uint16(bank&1)*VRAM_BANK_SIZE]
+}
+
+func (bus *Bus) PpuReadBgPalette(idx byte) byte {
+	return bus.bgPaletteRam[idx&0x3F]
+}
+
+func (bus *Bus) PpuReadObjPalette(idx byte) byte {
+	return bus.objPaletteRam[idx&0x3F]
+}
+
+func (bus *Bus) SetSgb(s *sgb.Sgb) {
+	bus.sgb = s
+}
+
+func (bus *Bus) GetModel() model.Model {
+	return bus.model
+}
+
+func (bus *Bus) GetHeaderChecksum() byte {
+	return bus.cart.GetHeaderChecksum()
+}
+
+func (bus *Bus) IsCGB() bool {
+	return bus.cgbMode
+}
+
 func (bus *Bus) PpuReadOam(addr uint16) byte {
 	return bus.oam[addr]
 }
@@ -341,3 +563,26 @@ func (bus *Bus) SetOamAccessible(access bool) {
 func (bus *Bus) ToggleInterrupt(val byte) {
 	bus.manager.ToggleInterruptRequest(val)
 }
+
+// writePaletteData stores a byte at the index held in the spec register (BCPS/OCPS)
+// and returns the new spec value, incremented if bit 7 is set.
+// Palette RAM is inaccessible while the PPU is drawing, but the index still increments.
+func writePaletteData(ram []byte, spec byte, value byte, accessible bool) byte {
+	idx := spec & 0x3F
+	if accessible {
+		ram[idx] = value
+	}
+
+	if spec&0x80 == 0x80 {
+		idx = (idx + 1) & 0x3F
+	}
+
+	return spec&0x80 | idx
+}
+
+func readPaletteData(ram []byte, spec byte, accessible bool) byte {
+	if !accessible {
+		return 0xFF
+	}
+	return ram[spec&0x3F]
+}
@@ -0,0 +1,101 @@
+package bus
+
+const (
+	HDMA_SOURCE_HIGH = 0xFF51
+	HDMA_SOURCE_LOW  = 0xFF52
+	HDMA_DEST_HIGH   = 0xFF53
+	HDMA_DEST_LOW    = 0xFF54
+	HDMA_CONTROL     = 0xFF55
+
+	VRAM_DMA_BLOCK_SIZE   = 0x10
+	VRAM_DMA_BLOCK_CYCLES = 32 // the CPU is halted for 8 M-cycles per block
+)
+
+// vramDma is the CGB VRAM DMA engine (HDMA1-HDMA5). A general purpose transfer copies
+// every block back-to-back, while an H-Blank transfer copies a single block each H-Blank.
+type vramDma struct {
+	source     uint16
+	dest       uint16
+	blocksLeft uint16
+	active     bool
+	hBlankMode bool
+	blockReady bool
+}
+
+func newVramDma() *vramDma {
+	return &vramDma{
+		source:     0,
+		dest:       0,
+		blocksLeft: 0,
+		active:     false,
+		hBlankMode: false,
+		blockReady: false,
+	}
+}
+
+func (d *vramDma) write(addr uint16, value byte) {
+	switch addr {
+	case HDMA_SOURCE_HIGH:
+		d.source = uint16(value)<<8 | d.source&0x00FF
+	case HDMA_SOURCE_LOW:
+		d.source = d.source&0xFF00 | uint16(value&0xF0)
+	case HDMA_DEST_HIGH:
+		d.dest = uint16(value&0x1F)<<8 | d.dest&0x00FF
+	case HDMA_DEST_LOW:
+		d.dest = d.dest&0xFF00 | uint16(value&0xF0)
+	case HDMA_CONTROL:
+		// clearing bit 7 during an H-Blank transfer cancels it
+		if d.active && d.hBlankMode && value&0x80 == 0 {
+			d.active = false
+			d.blockReady = false
+			return
+		}
+
+		d.blocksLeft = uint16(value&0x7F) + 1
+		d.hBlankMode = value&0x80 == 0x80
+		d.blockReady = !d.hBlankMode
+		d.active = true
+	}
+}
+
+func (d *vramDma) read() byte {
+	length := byte(d.blocksLeft-1) & 0x7F
+	if d.active {
+		return length
+	}
+	return 0x80 | length
+}
+
+// SignalHBlank lets an H-Blank transfer copy its next block
+func (bus *Bus) SignalHBlank() {
+	if bus.hdma.active && bus.hdma.hBlankMode {
+		bus.hdma.blockReady = true
+	}
+}
+
+// CycleVramDma copies one block if a transfer is waiting on it, returning the number of cycles
+// the CPU is halted for. Zero means no transfer took place.
+func (bus *Bus) CycleVramDma() byte {
+	d := bus.hdma
+	if !d.active || !d.blockReady {
+		return 0
+	}
+
+	vramOffset := uint16(bus.vramBank) * VRAM_BANK_SIZE
+	for i := uint16(0); i < VRAM_DMA_BLOCK_SIZE; i++ {
+		bus.videoRam[vramOffset+(d.dest+i)&0x1FFF] = bus.Read(d.source + i)
+	}
+
+	d.source += VRAM_DMA_BLOCK_SIZE
+	d.dest = (d.dest + VRAM_DMA_BLOCK_SIZE) & 0x1FFF
+	d.blocksLeft--
+
+	if d.blocksLeft == 0 || d.dest == 0 {
+		d.active = false
+	}
+	if d.hBlankMode {
+		d.blockReady = false
+	}
+
+	return VRAM_DMA_BLOCK_CYCLES
+}
@@ -0,0 +1,123 @@
+package camera
+
+import (
+	"fmt"
+	"github.com/siliconandsolder/go-boy/pkg/cartridge"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+var imageExtensions = []string{".png", ".jpg", ".jpeg", ".gif"}
+
+// Source feeds still images to the Pocket Camera's sensor. A directory is treated as a sequence of frames,
+// moving on to the next one each time a picture is taken.
+type Source struct {
+	frames [][]byte
+	next   int
+}
+
+// NewSource loads an image file, or every image in a directory in name order
+func NewSource(path string) (*Source, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			ext := strings.ToLower(filepath.Ext(entry.Name()))
+			if !entry.IsDir() && slices.Contains(imageExtensions, ext) {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no images found in %s", path)
+		}
+	} else {
+		files = []string{path}
+	}
+
+	source := &Source{
+		frames: make([][]byte, 0, len(files)),
+		next:   0,
+	}
+
+	for _, file := range files {
+		frame, err := loadFrame(file)
+		if err != nil {
+			return nil, fmt.Errorf("could not load %s: %w", file, err)
+		}
+		source.frames = append(source.frames, frame)
+	}
+
+	return source, nil
+}
+
+// NextFrame returns the next frame, looping back to the first after the last
+func (s *Source) NextFrame() []byte {
+	frame := s.frames[s.next]
+	s.next = (s.next + 1) % len(s.frames)
+	return frame
+}
+
+func loadFrame(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return toSensorImage(img), nil
+}
+
+// toSensorImage scales the image to fill the sensor, cropping whichever dimension overflows,
+// and averages each block of source pixels into one greyscale value
+func toSensorImage(img image.Image) []byte {
+	bounds := img.Bounds()
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+
+	scale := min(float64(srcWidth)/cartridge.CAMERA_WIDTH, float64(srcHeight)/cartridge.CAMERA_HEIGHT)
+	cropX := (float64(srcWidth) - scale*cartridge.CAMERA_WIDTH) / 2
+	cropY := (float64(srcHeight) - scale*cartridge.CAMERA_HEIGHT) / 2
+
+	frame := make([]byte, cartridge.CAMERA_WIDTH*cartridge.CAMERA_HEIGHT)
+	for y := 0; y < cartridge.CAMERA_HEIGHT; y++ {
+		for x := 0; x < cartridge.CAMERA_WIDTH; x++ {
+			startX := bounds.Min.X + int(cropX+float64(x)*scale)
+			startY := bounds.Min.Y + int(cropY+float64(y)*scale)
+			endX := max(bounds.Min.X+int(cropX+float64(x+1)*scale), startX+1)
+			endY := max(bounds.Min.Y+int(cropY+float64(y+1)*scale), startY+1)
+
+			total, count := 0, 0
+			for sy := startY; sy < endY && sy < bounds.Max.Y; sy++ {
+				for sx := startX; sx < endX && sx < bounds.Max.X; sx++ {
+					total += int(color.GrayModel.Convert(img.At(sx, sy)).(color.Gray).Y)
+					count++
+				}
+			}
+
+			if count > 0 {
+				frame[y*cartridge.CAMERA_WIDTH+x] = byte(total / count)
+			}
+		}
+	}
+
+	return frame
+}
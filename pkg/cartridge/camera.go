@@ -0,0 +1,260 @@
+package cartridge
+
+const (
+	POCKET_CAMERA = 0xFC
+
+	CAMERA_REGISTERS_SELECT = 0x10
+	CAMERA_REGISTER_MASK    = 0x7F
+	CAMERA_REGISTER_COUNT   = 0x36
+
+	CAMERA_WIDTH  = 128
+	CAMERA_HEIGHT = 112
+
+	// the picture is written as tiles into the first RAM bank
+	CAMERA_IMAGE_START = 0x100
+)
+
+// M64282FP sensor registers, mapped to 0xA000-0xA035 when the RAM bank has bit 4 set
+const (
+	CAM_CONTROL   = 0x00 // bit 0 starts a capture and stays set until it finishes
+	CAM_EDGE_GAIN = 0x01 // N in bit 7, VH in bits 5-6, gain in bits 0-4
+	CAM_EXPOSURE  = 0x02 // 16 bit, big endian
+	CAM_EDGE_INV  = 0x04 // edge ratio in bits 4-6, invert in bit 3
+	CAM_OFFSET    = 0x05
+	CAM_DITHER    = 0x06 // 4x4 matrix, three thresholds per pixel
+)
+
+// the capture takes this long at the shortest exposure, in cycles
+const (
+	CAMERA_CAPTURE_BASE = 129792
+	CAMERA_CAPTURE_N    = 2048
+	CAMERA_EXPOSURE_MUL = 64
+
+	// an exposure of 0x0800 leaves the sensor values unchanged
+	CAMERA_UNIT_EXPOSURE = 0x0800
+)
+
+var edgeRatios = [8]float64{0.5, 0.75, 1, 1.25, 2, 3, 4, 5}
+
+// PocketCamera is the Game Boy Camera's mapper. It banks like MBC3 without the clock,
+// and can swap RAM out for the registers of the image sensor.
+type PocketCamera struct {
+	ramEnabled  bool
+	romBank     byte
+	lastRomBank uint16
+	ramBank     byte
+	lastRamBank byte
+
+	registers   []byte
+	captureTime uint32
+
+	// the cartridge's RAM, which finished pictures are written into
+	sram []byte
+
+	// returns a greyscale image, CAMERA_WIDTH x CAMERA_HEIGHT, one byte per pixel
+	source func() []byte
+}
+
+func NewPocketCamera(romInfo RomInfo, ramInfo RamInfo) *PocketCamera {
+	var lastRamBank byte = 0
+	if ramInfo.NumBanks > 0 {
+		lastRamBank = ramInfo.NumBanks - 1
+	}
+
+	return &PocketCamera{
+		ramEnabled:  false,
+		romBank:     1,
+		lastRomBank: romInfo.NumBanks - 1,
+		ramBank:     0,
+		lastRamBank: lastRamBank,
+		registers:   make([]byte, CAMERA_REGISTER_COUNT),
+		captureTime: 0,
+		sram:        nil,
+		source:      nil,
+	}
+}
+
+func (p *PocketCamera) Read(addr uint16) (uint32, bool) {
+	if addr <= LOWER_ROM_BANK_END {
+		return uint32(addr), true
+	} else if addr >= UPPER_ROM_BANK_START && addr <= UPPER_ROM_BANK_END {
+		return uint32(addr-UPPER_ROM_BANK_START) + uint32(uint16(p.romBank)&p.lastRomBank)*0x4000, true
+	} else if addr >= RAM_BANK_START && addr <= RAM_BANK_END {
+		if p.ramBank&CAMERA_REGISTERS_SELECT != 0 {
+			// only the control register can be read back
+			if addr&CAMERA_REGISTER_MASK == CAM_CONTROL {
+				return uint32(p.registers[CAM_CONTROL]), false
+			}
+			return 0, false
+		}
+
+		if p.captureTime > 0 {
+			// RAM is disconnected while the sensor is writing to it
+			return 0, false
+		}
+
+		// RAM can be read even when it is disabled
+		return uint32(addr-RAM_BANK_START) + 0x2000*uint32(p.ramBank&p.lastRamBank), true
+	}
+
+	return 0xFF, false
+}
+
+func (p *PocketCamera) Write(addr uint16, data byte) (uint32, bool) {
+	if addr <= RAM_ENABLE_END {
+		p.ramEnabled = data&0x0F == 0x0A
+	} else if addr <= ROM_BANK_SELECT_END {
+		p.romBank = data & 0x3F
+	} else if addr <= RAM_BANK_SELECT_END {
+		p.ramBank = data & 0x1F
+	} else if addr >= RAM_BANK_START && addr <= RAM_BANK_END {
+		if p.ramBank&CAMERA_REGISTERS_SELECT != 0 {
+			p.writeRegister(byte(addr&CAMERA_REGISTER_MASK), data)
+		} else if p.ramEnabled && p.captureTime == 0 {
+			return uint32(addr-RAM_BANK_START) + 0x2000*uint32(p.ramBank&p.lastRamBank), true
+		}
+	}
+
+	return 0, false
+}
+
+func (p *PocketCamera) writeRegister(reg byte, data byte) {
+	if reg >= CAMERA_REGISTER_COUNT {
+		return
+	}
+
+	if reg == CAM_CONTROL {
+		if data&1 == 1 && p.captureTime == 0 {
+			p.captureTime = p.getCaptureTime()
+		} else if data&1 == 0 {
+			// clearing the bit cancels the capture
+			p.captureTime = 0
+		}
+		p.registers[CAM_CONTROL] = data & 0x07
+		if p.captureTime == 0 {
+			p.registers[CAM_CONTROL] &^= 1
+		}
+		return
+	}
+
+	p.registers[reg] = data
+}
+
+func (p *PocketCamera) getCaptureTime() uint32 {
+	time := uint32(CAMERA_CAPTURE_BASE) + uint32(p.getExposure())*CAMERA_EXPOSURE_MUL
+	if p.registers[CAM_EDGE_GAIN]&0x80 == 0 {
+		time += CAMERA_CAPTURE_N
+	}
+	return time
+}
+
+func (p *PocketCamera) getExposure() uint16 {
+	return uint16(p.registers[CAM_EXPOSURE])<<8 | uint16(p.registers[CAM_EXPOSURE+1])
+}
+
+func (p *PocketCamera) addCycles(cycles byte) {
+	if p.captureTime == 0 {
+		return
+	}
+
+	if p.captureTime > uint32(cycles) {
+		p.captureTime -= uint32(cycles)
+		return
+	}
+
+	p.captureTime = 0
+	p.registers[CAM_CONTROL] &^= 1
+	p.capture()
+}
+
+// capture runs the sensor image through the exposure, edge enhancement and dithering stages,
+// then writes the result to RAM as 16x14 tiles
+func (p *PocketCamera) capture() {
+	if p.sram == nil {
+		return
+	}
+
+	raw := p.getSensorImage()
+	exposure := float64(p.getExposure()) / CAMERA_UNIT_EXPOSURE
+
+	exposed := make([]float64, len(raw))
+	for i, val := range raw {
+		exposed[i] = float64(val) * exposure
+	}
+
+	// N selects 2D enhancement, otherwise VH picks the direction
+	mode := byte(3)
+	if p.registers[CAM_EDGE_GAIN]&0x80 != 0 {
+		mode = p.registers[CAM_EDGE_GAIN] >> 5 & 3
+	}
+	ratio := edgeRatios[p.registers[CAM_EDGE_INV]>>4&7]
+	invert := p.registers[CAM_EDGE_INV]&0x08 != 0
+
+	pixel := func(x int, y int) float64 {
+		x = min(max(x, 0), CAMERA_WIDTH-1)
+		y = min(max(y, 0), CAMERA_HEIGHT-1)
+		return exposed[y*CAMERA_WIDTH+x]
+	}
+
+	for y := 0; y < CAMERA_HEIGHT; y++ {
+		for x := 0; x < CAMERA_WIDTH; x++ {
+			val := pixel(x, y)
+			switch mode {
+			case 1:
+				val += (2*val - pixel(x-1, y) - pixel(x+1, y)) * ratio
+			case 2:
+				val += (2*val - pixel(x, y-1) - pixel(x, y+1)) * ratio
+			case 3:
+				val += (4*val - pixel(x-1, y) - pixel(x+1, y) - pixel(x, y-1) - pixel(x, y+1)) * ratio
+			}
+
+			val = min(max(val, 0), 255)
+			if invert {
+				val = 255 - val
+			}
+
+			p.setPixel(x, y, p.dither(x, y, byte(val)))
+		}
+	}
+}
+
+// dither compares the value with the matrix thresholds for its position, darkest shade first
+func (p *PocketCamera) dither(x int, y int, val byte) byte {
+	thresholds := p.registers[CAM_DITHER+((y&3)*4+(x&3))*3:]
+	if val < thresholds[0] {
+		return 3
+	} else if val < thresholds[1] {
+		return 2
+	} else if val < thresholds[2] {
+		return 1
+	}
+	return 0
+}
+
+func (p *PocketCamera) setPixel(x int, y int, shade byte) {
+	tile := (y/8)*(CAMERA_WIDTH/8) + x/8
+	addr := CAMERA_IMAGE_START + tile*16 + (y&7)*2
+	bit := byte(7 - x&7)
+
+	p.sram[addr] = p.sram[addr]&^(1<<bit) | (shade&1)<<bit
+	p.sram[addr+1] = p.sram[addr+1]&^(1<<bit) | (shade>>1)<<bit
+}
+
+func (p *PocketCamera) getSensorImage() []byte {
+	if p.source != nil {
+		if img := p.source(); len(img) == CAMERA_WIDTH*CAMERA_HEIGHT {
+			return img
+		}
+	}
+
+	// without an image, the sensor just sees noise
+	img := make([]byte, CAMERA_WIDTH*CAMERA_HEIGHT)
+	var seed uint32 = 0x12345678
+	for i := range img {
+		seed ^= seed << 13
+		seed ^= seed >> 17
+		seed ^= seed << 5
+		img[i] = byte(seed)
+	}
+	return img
+}
@@ -1,10 +1,12 @@
 package cartridge
 
 import (
-	"encoding/json"
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
 	"github.com/siliconandsolder/go-boy/pkg/cartridge/rtc"
 	"os"
+	"path/filepath"
 	"slices"
 	"strings"
 )
@@ -13,8 +15,18 @@ const (
 	ROM_ONLY    = 0x00
 	MBC_1_START = 0x01
 	MBC_1_END   = 0x03
+	MBC_2_START = 0x05
+	MBC_2_END   = 0x06
 	MBC_3_START = 0x0F
 	MBC_3_END   = 0x13
+	MBC_5_START = 0x19
+	MBC_5_END   = 0x1E
+
+	MBC_5_RUMBLE_START = 0x1C
+
+	MBC_7 = 0x22
+	HUC_3 = 0xFE
+	HUC_1 = 0xFF
 )
 
 const (
@@ -26,48 +38,195 @@ const (
 	RAM_END   = 0xBFFF
 )
 
-var batteryCartridges = []byte{0x03, 0x06, 0x09, 0x0D, 0x0F, 0x10, 0x13, 0x1B, 0x1E, 0x22, 0xFF}
+const (
+	DEFAULT_SAVE_DIR = "saves"
+	SAVE_NAME_FILE   = "file"
+	SAVE_NAME_HASH   = "hash"
+)
+
+var batteryCartridges = []byte{0x03, 0x06, 0x09, 0x0D, 0x0F, 0x10, 0x13, 0x1B, 0x1E, 0x22, 0xFC, 0xFE, 0xFF}
 
 type Cartridge struct {
 	Title      string
+	romName    string
+	saveDir    string
+	saveNaming string
+	romHash    string // of the file as loaded, before any mirroring
+	dirty      bool   // RAM has been written since the last save
 	header     *Header
 	mbc        MBC
 	rom        []byte
 	ram        []byte
 	state      *rtc.State
+	huc3Clock  *rtc.HuC3Clock
 	hasBattery bool
-}
-
-type SaveFile struct {
-	Sram        []byte
-	RtcSnapshot *rtc.StateSnapshot
+	ramMask    byte // bits of each RAM byte that aren't backed by memory
 }
 
 func NewCartridge(file []byte) *Cartridge {
-	header := NewHeader(file[HEADER_START:])
+	return NewCartridgeWithMapper(file, MAPPER_AUTO)
+}
 
-	if err := verifyChecksum(header.HeaderChecksum, file[CHECKSUM_LOWER_BOUND:CHECKSUM_UPPER_BOUND]); err != nil {
+// NewCartridgeWithMapper ignores the cartridge type in the header and uses the named mapper instead,
+// unless the name is MAPPER_AUTO. Unlicensed mappers are detected automatically.
+func NewCartridgeWithMapper(file []byte, mapperName string) *Cartridge {
+	if err := CheckMapperName(mapperName); err != nil {
 		panic(err)
 	}
 
-	mapper, state, err := getMBC(header)
-	if err != nil {
-		panic(err)
+	if mapperName == MAPPER_AUTO {
+		mapperName = detectUnlicensed(file)
+	}
+
+	headerData := file[HEADER_START:]
+	if mapperName == SACHEN_MMC1 || mapperName == SACHEN_MMC2 {
+		headerData = unscrambleHeader(file)
+	}
+	header := NewHeader(headerData)
+
+	if cartType, ok := forcedCartTypes[mapperName]; ok {
+		header.CartType = cartType
 	}
 
-	rom := make([]byte, header.RomSize.Size)
+	var mapper MBC
+	var state *rtc.State
+	hasBattery := slices.Contains(batteryCartridges, header.CartType)
+	if isUnlicensedMapper(mapperName) {
+		// unlicensed headers don't bother with correct checksums and sizes
+		header.RomSize = romInfoFromSize(len(file))
+		if mapperName != LI_CHENG && mapperName != NT_MULTICART {
+			header.RamSize = RamInfo{}
+			hasBattery = false
+		}
+		mapper = getUnlicensedMBC(mapperName, header)
+	} else {
+		if err := verifyChecksum(header.HeaderChecksum, file[CHECKSUM_LOWER_BOUND:CHECKSUM_UPPER_BOUND]); err != nil {
+			panic(err)
+		}
+
+		// overdumps are kept whole, since the extra banks are usually mirrors anyway
+		header.RomSize = romInfoFromSize(max(len(file), int(header.RomSize.Size)))
+
+		var err error
+		if mapper, state, err = getMBC(header); err != nil {
+			panic(err)
+		}
+	}
+
+	if mbc1, ok := mapper.(*MBC1); ok {
+		mbc1.isMulticart = isMulticart(file)
+	}
+
+	rom := mirrorRom(file, header.RomSize.Size)
 	ram := make([]byte, header.RamSize.Size)
 
-	rom = file
+	var ramMask byte = 0
+	if _, ok := mapper.(*MBC2); ok {
+		// the header reports no RAM, since it is built into the mapper
+		ram = make([]byte, MBC2_RAM_SIZE)
+		ramMask = MBC2_RAM_OPEN_BITS
+	} else if mbc7, ok := mapper.(*MBC7); ok {
+		// the EEPROM is saved in place of RAM
+		ram = mbc7.eeprom.data
+	} else if camera, ok := mapper.(*PocketCamera); ok {
+		camera.sram = ram
+	}
+
+	var huc3Clock *rtc.HuC3Clock = nil
+	if huc3, ok := mapper.(*HuC3); ok {
+		huc3Clock = huc3.clock
+	}
 
 	return &Cartridge{
 		Title:      header.Title,
+		romName:    "",
+		saveDir:    DEFAULT_SAVE_DIR,
+		saveNaming: SAVE_NAME_FILE,
+		romHash:    HashRom(file),
+		dirty:      false,
 		header:     header,
 		mbc:        mapper,
 		rom:        rom,
 		ram:        ram,
 		state:      state,
-		hasBattery: slices.Contains(batteryCartridges, header.CartType),
+		huc3Clock:  huc3Clock,
+		hasBattery: hasBattery,
+		ramMask:    ramMask,
+	}
+}
+
+// IsCGB reports whether the cartridge should be run with Game Boy Color features enabled
+func (c *Cartridge) IsCGB() bool {
+	return c.header.CGBSupport
+}
+
+// IsSGB reports whether the cartridge uses Super Game Boy functions. The SGB ignores the flag unless the old licence code is 0x33.
+func (c *Cartridge) IsSGB() bool {
+	return c.header.SGBFlag && c.header.OldLicenceCode == USE_NEW_LIC_CODE
+}
+
+func (c *Cartridge) GetHeaderChecksum() byte {
+	return c.header.HeaderChecksum
+}
+
+// GetTitleBytes returns the whole 16 byte title area, including the bytes later reused for the manufacturer code and CGB flag
+func (c *Cartridge) GetTitleBytes() []byte {
+	start := HEADER_START + TITLE
+	return c.rom[start : start+16]
+}
+
+func (c *Cartridge) IsNintendoLicensed() bool {
+	if c.header.OldLicenceCode == USE_NEW_LIC_CODE {
+		return c.header.LicenceCode == "01"
+	}
+	return c.header.OldLicenceCode == NINTENDO_LIC_CODE
+}
+
+// SetRomName names saves after the ROM file instead of the title in the header
+func (c *Cartridge) SetRomName(name string) {
+	c.romName = name
+}
+
+// ResetForBootRom returns the mapper to its power-on state, for mappers that the boot ROM changes
+func (c *Cartridge) ResetForBootRom() {
+	if sachen, ok := c.mbc.(*Sachen); ok {
+		sachen.lock()
+	}
+}
+
+// SetMulticart overrides MBC1M detection. It does nothing for cartridges that don't use MBC1.
+func (c *Cartridge) SetMulticart(enabled bool) {
+	if mbc1, ok := c.mbc.(*MBC1); ok {
+		mbc1.isMulticart = enabled
+	}
+}
+
+// HasTilt reports whether the cartridge has an accelerometer
+func (c *Cartridge) HasTilt() bool {
+	_, ok := c.mbc.(*MBC7)
+	return ok
+}
+
+// SetTilt passes the tilt of each axis, from -1 to 1, to the cartridge's accelerometer
+func (c *Cartridge) SetTilt(x float64, y float64) {
+	if mbc7, ok := c.mbc.(*MBC7); ok {
+		mbc7.SetTilt(x, y)
+	}
+}
+
+// SetRumbleCallback is called with the motor state whenever a rumble cartridge turns it on or off.
+// It does nothing for cartridges without a motor.
+func (c *Cartridge) SetRumbleCallback(callback func(on bool)) {
+	if mbc5, ok := c.mbc.(*MBC5); ok && mbc5.hasRumble {
+		mbc5.onRumble = callback
+	}
+}
+
+// SetCameraSource sets where the Pocket Camera's sensor gets its pictures from. The source returns a
+// CAMERA_WIDTH x CAMERA_HEIGHT greyscale image. It does nothing for other cartridges.
+func (c *Cartridge) SetCameraSource(source func() []byte) {
+	if camera, ok := c.mbc.(*PocketCamera); ok {
+		camera.source = source
 	}
 }
 
@@ -75,13 +234,19 @@ func (c *Cartridge) UpdateCounter(cycles byte) {
 	if c.state != nil {
 		c.state.AddCycles(cycles)
 	}
+	if c.huc3Clock != nil {
+		c.huc3Clock.AddCycles(cycles)
+	}
+	if camera, ok := c.mbc.(*PocketCamera); ok {
+		camera.addCycles(cycles)
+	}
 }
 
 func (c *Cartridge) Read(addr uint16) byte {
 	val, isAddr := c.mbc.Read(addr)
 	if isAddr {
 		if addr >= RAM_START && addr <= RAM_END {
-			return c.ram[val]
+			return c.ram[val] | c.ramMask
 		}
 		return c.rom[val]
 	}
@@ -91,58 +256,111 @@ func (c *Cartridge) Read(addr uint16) byte {
 
 func (c *Cartridge) Write(addr uint16, data byte) {
 	val, isAddr := c.mbc.Write(addr, data)
-	if isAddr && addr >= RAM_START && addr <= RAM_END {
-		c.ram[val] = data
+	if addr >= RAM_START && addr <= RAM_END {
+		if isAddr {
+			// GameShark codes for the selected bank land here every frame, so only a change makes the save dirty
+			if stored := data &^ c.ramMask; c.ram[val] != stored {
+				c.ram[val] = stored
+				c.dirty = true
+			}
+		} else if mapper, ok := c.mbc.(stateMBC); ok && mapper.takeStateChanged() {
+			c.dirty = true
+		}
 	}
 }
 
-func (c *Cartridge) SaveRAMToFile() {
-	if c.hasBattery {
-		if c.Title == "" {
-			return
-		}
+// WriteRamBank writes straight into a bank of cartridge RAM, whatever the mapper has selected.
+// Addresses past the end of RAM are ignored.
+func (c *Cartridge) WriteRamBank(bank byte, addr uint16, value byte) {
+	idx := uint32(bank)*0x2000 + uint32(addr-RAM_START)
+	if idx < uint32(len(c.ram)) && c.ram[idx] != value&^c.ramMask {
+		// GameShark codes rewrite the same value every frame, which shouldn't keep the save dirty
+		c.ram[idx] = value &^ c.ramMask
+		c.dirty = true
+	}
+}
 
-		sram := make([]byte, c.header.RamSize.Size)
-		copy(sram, c.ram)
+// ReadRamBank reads straight from a bank of cartridge RAM, whatever the mapper has selected.
+// Addresses past the end of RAM read as 0xFF.
+func (c *Cartridge) ReadRamBank(bank byte, addr uint16) byte {
+	idx := uint32(bank)*0x2000 + uint32(addr-RAM_START)
+	if idx < uint32(len(c.ram)) {
+		return c.ram[idx] | c.ramMask
+	}
+	return 0xFF
+}
 
-		var snapshot *rtc.StateSnapshot = nil
-		if c.state != nil {
-			snapshot = c.state.GetSnapshot()
-		}
+// GetRamSize returns the size of cartridge RAM in bytes
+func (c *Cartridge) GetRamSize() int {
+	return len(c.ram)
+}
 
-		save := SaveFile{
-			Sram:        sram,
-			RtcSnapshot: snapshot,
-		}
+// SaveRAMToFile writes the battery save, replacing the old one only once the new one is safely on disk
+func (c *Cartridge) SaveRAMToFile() error {
+	if !c.hasBattery {
+		return nil
+	}
 
-		saveJson, err := json.Marshal(save)
-		if err != nil {
-			panic(err)
-		}
+	saveTitle := c.getSaveName()
+	if saveTitle == "" {
+		return nil
+	}
 
-		if _, err := os.Stat("saves"); os.IsNotExist(err) {
-			err = os.Mkdir("saves", 0777)
-			if err != nil {
-				panic(err)
-			}
-		}
+	sram := make([]byte, len(c.ram))
+	copy(sram, c.ram)
 
-		saveTitle := fmt.Sprintf("%s.sav", strings.ToLower(strings.ReplaceAll(c.Title, " ", "_")))
-		err = os.WriteFile("saves/"+saveTitle, saveJson, 0777)
-		if err != nil {
-			panic(err)
-		}
+	var snapshot *rtc.StateSnapshot = nil
+	if c.state != nil {
+		snapshot = c.state.GetSnapshot()
+	}
+
+	var huc3Snapshot *rtc.HuC3Snapshot = nil
+	if c.huc3Clock != nil {
+		huc3Snapshot = c.huc3Clock.GetSnapshot()
+	}
+
+	save := SaveFile{
+		Sram:         sram,
+		RtcSnapshot:  snapshot,
+		HuC3Snapshot: huc3Snapshot,
 	}
+
+	if err := os.MkdirAll(c.saveDir, 0777); err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(filepath.Join(c.saveDir, saveTitle), EncodeRawSave(&save)); err != nil {
+		return err
+	}
+
+	c.dirty = false
+	return nil
+}
+
+// FlushRAM saves only if the game has written to RAM since the last save
+func (c *Cartridge) FlushRAM() error {
+	if !c.dirty {
+		return nil
+	}
+	return c.SaveRAMToFile()
 }
 
 func (c *Cartridge) LoadRAMFromFile() {
 	if c.hasBattery {
-		if c.Title == "" {
+		saveTitle := c.getSaveName()
+		if saveTitle == "" {
 			return
 		}
 
-		saveTitle := fmt.Sprintf("%s.sav", strings.ToLower(strings.ReplaceAll(c.Title, " ", "_")))
-		saveData, err := os.ReadFile("saves/" + saveTitle)
+		savePath := filepath.Join(c.saveDir, saveTitle)
+		saveData, err := os.ReadFile(savePath)
+		for _, oldTitle := range c.getOldSaveNames() {
+			if !os.IsNotExist(err) {
+				break
+			}
+			savePath = filepath.Join(c.saveDir, oldTitle)
+			saveData, err = os.ReadFile(savePath)
+		}
 		if err != nil {
 			if os.IsNotExist(err) {
 				fmt.Printf("no save file for: %s\n", c.Title)
@@ -152,19 +370,124 @@ func (c *Cartridge) LoadRAMFromFile() {
 			}
 		}
 
-		save := SaveFile{}
-		err = json.Unmarshal(saveData, &save)
+		save, isJson, err := DecodeSave(saveData, len(c.ram))
 		if err != nil {
-			fmt.Printf("could not read save: %v\n", err)
+			// move it out of the way, so saving the blank RAM the game starts with doesn't destroy it
+			if renameErr := os.Rename(savePath, savePath+".unreadable"); renameErr != nil {
+				panic(renameErr)
+			}
+			fmt.Printf("could not read save, kept it as %s.unreadable: %v\n", savePath, err)
 			return
 		}
 
 		copy(c.ram, save.Sram)
 
-		if save.RtcSnapshot != nil {
+		if save.RtcSnapshot != nil && c.state != nil {
 			c.state.FromSnapshot(save.RtcSnapshot)
 		}
+		if save.HuC3Snapshot != nil && c.huc3Clock != nil {
+			c.huc3Clock.FromSnapshot(save.HuC3Snapshot)
+		}
+
+		if isJson {
+			// keep the old save around, then replace it with a raw one
+			if err := os.WriteFile(filepath.Join(c.saveDir, saveTitle+".json.bak"), saveData, 0666); err != nil {
+				panic(err)
+			}
+			if err := c.SaveRAMToFile(); err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+// SetSaveDir changes where battery saves are kept, which is "saves" in the working directory by default
+func (c *Cartridge) SetSaveDir(dir string) {
+	c.saveDir = dir
+}
+
+// SetSaveNaming chooses whether saves are named after the ROM file or the ROM's SHA-1 hash.
+// Naming by hash stops games with the same file name or title from sharing a save.
+func (c *Cartridge) SetSaveNaming(naming string) error {
+	if naming != SAVE_NAME_FILE && naming != SAVE_NAME_HASH {
+		return fmt.Errorf("unknown save naming: %s", naming)
+	}
+	c.saveNaming = naming
+	return nil
+}
+
+// HashRom returns the SHA-1 of a ROM file, which names saves and picks out a game's settings in the config file
+func HashRom(file []byte) string {
+	hash := sha1.Sum(file)
+	return hex.EncodeToString(hash[:])
+}
+
+func (c *Cartridge) getSaveName() string {
+	if c.saveNaming == SAVE_NAME_HASH {
+		return c.romHash + ".sav"
+	} else if c.romName != "" {
+		return toSaveName(strings.TrimSuffix(c.romName, filepath.Ext(c.romName)))
+	} else if c.Title != "" {
+		return toSaveName(c.Title)
+	}
+	return ""
+}
+
+// getOldSaveNames lists the names a save may have been given before the current naming was chosen
+func (c *Cartridge) getOldSaveNames() []string {
+	var names []string
+	// hashes used to be taken after mirroring, which changes the hash of dumps that aren't a whole number of banks
+	if mirroredHash := HashRom(c.rom); c.saveNaming == SAVE_NAME_HASH && mirroredHash != c.romHash {
+		names = append(names, mirroredHash+".sav")
+	}
+	if c.saveNaming == SAVE_NAME_HASH && c.romName != "" {
+		names = append(names, toSaveName(strings.TrimSuffix(c.romName, filepath.Ext(c.romName))))
+	}
+	if c.Title != "" && (c.saveNaming == SAVE_NAME_HASH || c.romName != "") {
+		names = append(names, toSaveName(c.Title))
+	}
+	return names
+}
+
+func toSaveName(name string) string {
+	return fmt.Sprintf("%s.sav", strings.ToLower(strings.ReplaceAll(name, " ", "_")))
+}
+
+// writeFileAtomic writes to a temporary file next to the destination and renames it over the top,
+// so a crash part way through leaves the old file intact
+func writeFileAtomic(path string, data []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name()) // fails harmlessly once renamed
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
+
+// mirrorRom repeats an undersized dump until it fills every bank the mapper can select
+func mirrorRom(file []byte, size uint32) []byte {
+	if uint32(len(file)) >= size {
+		return file
+	}
+
+	rom := make([]byte, size)
+	for i := 0; i < len(rom); i += len(file) {
+		copy(rom[i:], file)
 	}
+	return rom
 }
 
 func verifyChecksum(verifier byte, verifyBytes []byte) error {
@@ -184,9 +507,22 @@ func getMBC(header *Header) (MBC, *rtc.State, error) {
 		return &RomOnly{}, nil, nil
 	} else if header.CartType >= MBC_1_START && header.CartType <= MBC_1_END {
 		return NewMBC1(header.RomSize, header.RamSize), nil, nil
+	} else if header.CartType >= MBC_2_START && header.CartType <= MBC_2_END {
+		return NewMBC2(header.RomSize), nil, nil
 	} else if header.CartType >= MBC_3_START && header.CartType <= MBC_3_END {
 		rtcState := rtc.NewState()
-		return NewMBC3(header.RomSize, header.RamSize, rtcState), rtcState, nil
+		isMBC30 := header.RomSize.NumBanks > MBC3_MAX_ROM_BANKS || header.RamSize.NumBanks > MBC3_MAX_RAM_BANKS
+		return NewMBC3(header.RomSize, header.RamSize, rtcState, isMBC30), rtcState, nil
+	} else if header.CartType >= MBC_5_START && header.CartType <= MBC_5_END {
+		return NewMBC5(header.RomSize, header.RamSize, header.CartType >= MBC_5_RUMBLE_START), nil, nil
+	} else if header.CartType == MBC_7 {
+		return NewMBC7(header.RomSize), nil, nil
+	} else if header.CartType == POCKET_CAMERA {
+		return NewPocketCamera(header.RomSize, header.RamSize), nil, nil
+	} else if header.CartType == HUC_1 {
+		return NewHuC1(header.RomSize, header.RamSize), nil, nil
+	} else if header.CartType == HUC_3 {
+		return NewHuC3(header.RomSize, header.RamSize, rtc.NewHuC3Clock()), nil, nil
 	}
 
 	return nil, nil, fmt.Errorf("cart type %d not yet implemented", header.CartType)
@@ -0,0 +1,164 @@
+package cartridge
+
+const (
+	EEPROM_CS  = 0x80
+	EEPROM_CLK = 0x40
+	EEPROM_DI  = 0x02
+	EEPROM_DO  = 0x01
+
+	EEPROM_COMMAND_BITS = 10 // 2 opcode bits followed by an 8-bit address
+	EEPROM_WORD_BITS    = 16
+)
+
+type eepromState byte
+
+const (
+	eepromIdle eepromState = iota
+	eepromCommand
+	eepromReading
+	eepromWriting
+	eepromWritingAll
+)
+
+// eeprom is the 93LC56 serial EEPROM used by MBC7, organised as 128 16-bit words.
+// Data is clocked in through DI on the rising edge of CLK while CS is high, starting with a 1 bit.
+type eeprom struct {
+	data         []byte
+	state        eepromState
+	cs           bool
+	clk          bool
+	out          byte
+	shift        uint16
+	bits         int
+	addr         byte
+	writeEnabled bool
+	changed      bool // data has been written since the cartridge last asked
+}
+
+func newEeprom() *eeprom {
+	return &eeprom{
+		data:         make([]byte, MBC7_EEPROM_SIZE),
+		state:        eepromIdle,
+		cs:           false,
+		clk:          false,
+		out:          1,
+		shift:        0,
+		bits:         0,
+		addr:         0,
+		writeEnabled: false,
+		changed:      false,
+	}
+}
+
+func (e *eeprom) read() byte {
+	var val byte = 0
+	if e.cs {
+		val |= EEPROM_CS
+	}
+	if e.clk {
+		val |= EEPROM_CLK
+	}
+	return val | e.out
+}
+
+func (e *eeprom) write(val byte) {
+	cs := val&EEPROM_CS == EEPROM_CS
+	clk := val&EEPROM_CLK == EEPROM_CLK
+	di := uint16(val&EEPROM_DI) >> 1
+
+	risingEdge := clk && !e.clk
+	e.clk = clk
+
+	if !cs {
+		// dropping CS ends the current command, and DO goes high to show it's ready
+		e.cs = false
+		e.state = eepromIdle
+		e.out = 1
+		return
+	}
+	e.cs = true
+
+	if !risingEdge {
+		return
+	}
+
+	switch e.state {
+	case eepromIdle:
+		if di == 1 { // start bit
+			e.state = eepromCommand
+			e.shift = 0
+			e.bits = 0
+		}
+	case eepromCommand:
+		e.shift = e.shift<<1 | di
+		e.bits++
+		if e.bits == EEPROM_COMMAND_BITS {
+			e.runCommand(byte(e.shift>>8), byte(e.shift))
+		}
+	case eepromReading:
+		e.out = byte(e.shift >> 15)
+		e.shift <<= 1
+		e.bits--
+		if e.bits == 0 {
+			e.state = eepromIdle
+		}
+	case eepromWriting, eepromWritingAll:
+		e.shift = e.shift<<1 | di
+		e.bits++
+		if e.bits == EEPROM_WORD_BITS {
+			if e.state == eepromWritingAll {
+				for i := 0; i < len(e.data)/2; i++ {
+					e.setWord(byte(i), e.shift)
+				}
+			} else {
+				e.setWord(e.addr, e.shift)
+			}
+			e.state = eepromIdle
+		}
+	}
+}
+
+func (e *eeprom) runCommand(opcode byte, addr byte) {
+	e.addr = addr & 0x7F
+	e.shift = 0
+	e.bits = 0
+	e.state = eepromIdle
+
+	switch opcode {
+	case 0b10: // READ, preceded by a dummy 0 bit
+		e.out = 0
+		e.shift = e.getWord(e.addr)
+		e.bits = EEPROM_WORD_BITS
+		e.state = eepromReading
+	case 0b01: // WRITE
+		e.state = eepromWriting
+	case 0b11: // ERASE
+		e.setWord(e.addr, 0xFFFF)
+	case 0b00:
+		switch addr >> 6 {
+		case 0b00: // EWDS
+			e.writeEnabled = false
+		case 0b01: // WRAL
+			e.state = eepromWritingAll
+		case 0b10: // ERAL
+			for i := 0; i < len(e.data)/2; i++ {
+				e.setWord(byte(i), 0xFFFF)
+			}
+		case 0b11: // EWEN
+			e.writeEnabled = true
+		}
+	}
+}
+
+func (e *eeprom) getWord(addr byte) uint16 {
+	return uint16(e.data[int(addr)*2+1])<<8 | uint16(e.data[int(addr)*2])
+}
+
+func (e *eeprom) setWord(addr byte, val uint16) {
+	if !e.writeEnabled {
+		return
+	}
+	e.data[int(addr)*2] = byte(val)
+	e.data[int(addr)*2+1] = byte(val >> 8)
+	e.changed = true
+}
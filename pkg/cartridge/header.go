@@ -1,5 +1,10 @@
 package cartridge
 
+import (
+	"bytes"
+	"fmt"
+)
+
 const (
 	LOGO            = 0x04
 	TITLE           = 0x34
@@ -14,8 +19,20 @@ const (
 	OLD_LIC_CODE    = 0x4B
 	ROM_VERSION     = 0x4C
 	HEADER_CHECKSUM = 0x4D
+	GLOBAL_CHECKSUM = 0x4E
+	HEADER_END      = 0x50
+
+	CGB_ONLY_CODE   = 0xC0
+	CGB_COMPAT_CODE = 0x80
 
-	CGB_ONLY_CODE = 0xC0
+	NINTENDO_LIC_CODE = 0x01
+	USE_NEW_LIC_CODE  = 0x33
+
+	DEST_JAPAN    = 0x00
+	DEST_OVERSEAS = 0x01
+
+	ROM_BANK_SIZE = 0x4000
+	MAX_ROM_BANKS = 512 // 8 MiB
 )
 
 var LogoBytes = []byte{
@@ -28,7 +45,8 @@ type Header struct {
 	Title          string
 	ManCode        string
 	CGBFlag        bool
-	LicenceCode    byte
+	CGBSupport     bool
+	LicenceCode    string
 	SGBFlag        bool
 	CartType       byte
 	RomSize        RomInfo
@@ -37,7 +55,8 @@ type Header struct {
 	OldLicenceCode byte
 	RomVersion     byte
 	HeaderChecksum byte
-	GlobalChecksum byte
+	GlobalChecksum uint16
+	LogoValid      bool
 }
 
 type RomInfo struct {
@@ -55,19 +74,55 @@ func NewHeader(data []byte) *Header {
 		Title:          sliceToString(data[TITLE:MAN_CODE]),
 		ManCode:        sliceToString(data[MAN_CODE:CGB_FLAG]),
 		CGBFlag:        data[CGB_FLAG] == CGB_ONLY_CODE,
-		LicenceCode:    0,
+		CGBSupport:     data[CGB_FLAG]&CGB_COMPAT_CODE == CGB_COMPAT_CODE,
+		LicenceCode:    string(data[NEW_LIC_CODE : NEW_LIC_CODE+2]),
 		SGBFlag:        data[SGB_CODE] == 0x3,
 		CartType:       data[CART_TYPE],
 		RomSize:        getRomData(data[ROM_SIZE]),
 		RamSize:        getRamData(data[RAM_SIZE]),
 		DestCode:       data[DEST_CODE],
-		OldLicenceCode: 0,
+		OldLicenceCode: data[OLD_LIC_CODE],
 		RomVersion:     data[ROM_VERSION],
 		HeaderChecksum: data[HEADER_CHECKSUM],
-		GlobalChecksum: 0,
+		GlobalChecksum: uint16(data[GLOBAL_CHECKSUM])<<8 | uint16(data[GLOBAL_CHECKSUM+1]),
+		LogoValid:      bytes.Equal(data[LOGO:LOGO+len(LogoBytes)], LogoBytes),
+	}
+}
+
+// GetLicensee returns the publisher's name, from the new licence code if the old one says to use it
+func (h *Header) GetLicensee() (string, error) {
+	if h.OldLicenceCode == USE_NEW_LIC_CODE {
+		return getNewLicenceNameFromCode(h.LicenceCode)
+	}
+	return getLicenceNameFromCode(h.OldLicenceCode)
+}
+
+func (h *Header) GetDestination() string {
+	switch h.DestCode {
+	case DEST_JAPAN:
+		return "Japan"
+	case DEST_OVERSEAS:
+		return "Overseas"
+	default:
+		return fmt.Sprintf("unknown (0x%02X)", h.DestCode)
 	}
 }
 
+// verifyGlobalChecksum sums every byte of the ROM except the checksum itself. Real hardware never checks it.
+func verifyGlobalChecksum(verifier uint16, rom []byte) error {
+	var checksum uint16 = 0
+	for i, val := range rom {
+		if i != HEADER_START+GLOBAL_CHECKSUM && i != HEADER_START+GLOBAL_CHECKSUM+1 {
+			checksum += uint16(val)
+		}
+	}
+
+	if checksum != verifier {
+		return fmt.Errorf("global checksum is invalid. was %d, should be %d", checksum, verifier)
+	}
+	return nil
+}
+
 func sliceToString(data []byte) string {
 	stringVal := ""
 	for _, val := range data {
@@ -130,6 +185,19 @@ func getRomData(romValue byte) RomInfo {
 	}
 }
 
+// romInfoFromSize rounds a dump's size up to the next ROM size a header can describe
+func romInfoFromSize(size int) RomInfo {
+	var numBanks uint16 = 2
+	for int(numBanks)*ROM_BANK_SIZE < size && numBanks < MAX_ROM_BANKS {
+		numBanks *= 2
+	}
+
+	return RomInfo{
+		Size:     uint32(numBanks) * ROM_BANK_SIZE,
+		NumBanks: numBanks,
+	}
+}
+
 func getRamData(ramValue byte) RamInfo {
 	switch ramValue {
 	case 0:
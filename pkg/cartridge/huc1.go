@@ -0,0 +1,62 @@
+package cartridge
+
+const (
+	HUC_IR_MODE = 0x0E
+
+	// the IR receiver reads 0xC1 when it sees light, 0xC0 when it doesn't
+	HUC_IR_NO_LIGHT = 0xC0
+)
+
+// HuC1 is similar to MBC1, but RAM is always enabled and the enable register switches it out for the IR port instead
+type HuC1 struct {
+	irMode      bool
+	romBank     byte
+	lastRomBank uint16
+	ramBank     byte
+	lastRamBank byte
+}
+
+func NewHuC1(romInfo RomInfo, ramInfo RamInfo) *HuC1 {
+	var lastRamBank byte = 0
+	if ramInfo.NumBanks > 0 {
+		lastRamBank = ramInfo.NumBanks - 1
+	}
+
+	return &HuC1{
+		irMode:      false,
+		romBank:     1,
+		lastRomBank: romInfo.NumBanks - 1,
+		ramBank:     0,
+		lastRamBank: lastRamBank,
+	}
+}
+
+func (h *HuC1) Read(addr uint16) (uint32, bool) {
+	if addr <= LOWER_ROM_BANK_END {
+		return uint32(addr), true
+	} else if addr >= UPPER_ROM_BANK_START && addr <= UPPER_ROM_BANK_END {
+		return uint32(addr-UPPER_ROM_BANK_START) + uint32(uint16(h.romBank)&h.lastRomBank)*0x4000, true
+	} else if addr >= RAM_BANK_START && addr <= RAM_BANK_END {
+		if h.irMode {
+			return HUC_IR_NO_LIGHT, false
+		}
+		return uint32(addr-RAM_BANK_START) + 0x2000*uint32(h.ramBank&h.lastRamBank), true
+	}
+
+	return 0xFF, false
+}
+
+func (h *HuC1) Write(addr uint16, data byte) (uint32, bool) {
+	if addr <= RAM_ENABLE_END {
+		h.irMode = data&0x0F == HUC_IR_MODE
+	} else if addr <= ROM_BANK_SELECT_END {
+		h.romBank = data & 0x3F
+	} else if addr <= RAM_BANK_SELECT_END {
+		h.ramBank = data & 0x03
+	} else if addr >= RAM_BANK_START && addr <= RAM_BANK_END && !h.irMode {
+		// writes in IR mode switch the LED, which nothing is listening to
+		return uint32(addr-RAM_BANK_START) + 0x2000*uint32(h.ramBank&h.lastRamBank), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,157 @@
+package cartridge
+
+import "github.com/siliconandsolder/go-boy/pkg/cartridge/rtc"
+
+// values written to 0x0000-0x1FFF, selecting what appears at 0xA000-0xBFFF
+const (
+	HUC3_RAM_READ   = 0x00
+	HUC3_RAM_WRITE  = 0x0A
+	HUC3_COMMAND    = 0x0B
+	HUC3_RESPONSE   = 0x0C
+	HUC3_SEMAPHORE  = 0x0D
+	HUC3_IR         = 0x0E
+	HUC3_MEMORY_LEN = 0x100
+)
+
+// commands are written as a high nibble, with an argument in the low nibble
+const (
+	huc3ReadNext    = 0x1
+	huc3WriteNext   = 0x3
+	huc3SetAddrLow  = 0x4
+	huc3SetAddrHigh = 0x5
+	huc3Extended    = 0x6
+
+	huc3LatchClock = 0x0
+	huc3SetClock   = 0x1
+	huc3Status     = 0x2
+)
+
+// HuC3 talks to its clock through a command and response interface. The clock lives in a small
+// nibble-wide memory, where the minutes are at 0x00-0x02 and the days at 0x03-0x05.
+type HuC3 struct {
+	mode        byte
+	romBank     byte
+	lastRomBank uint16
+	ramBank     byte
+	lastRamBank byte
+
+	command  byte
+	response byte
+	address  byte
+	memory   []byte
+
+	clock    *rtc.HuC3Clock
+	clockSet bool
+}
+
+func NewHuC3(romInfo RomInfo, ramInfo RamInfo, clock *rtc.HuC3Clock) *HuC3 {
+	var lastRamBank byte = 0
+	if ramInfo.NumBanks > 0 {
+		lastRamBank = ramInfo.NumBanks - 1
+	}
+
+	return &HuC3{
+		mode:        HUC3_RAM_READ,
+		romBank:     1,
+		lastRomBank: romInfo.NumBanks - 1,
+		ramBank:     0,
+		lastRamBank: lastRamBank,
+		command:     0,
+		response:    0,
+		address:     0,
+		memory:      make([]byte, HUC3_MEMORY_LEN),
+		clock:       clock,
+		clockSet:    false,
+	}
+}
+
+func (h *HuC3) Read(addr uint16) (uint32, bool) {
+	if addr <= LOWER_ROM_BANK_END {
+		return uint32(addr), true
+	} else if addr >= UPPER_ROM_BANK_START && addr <= UPPER_ROM_BANK_END {
+		return uint32(addr-UPPER_ROM_BANK_START) + uint32(uint16(h.romBank)&h.lastRomBank)*0x4000, true
+	} else if addr >= RAM_BANK_START && addr <= RAM_BANK_END {
+		switch h.mode {
+		case HUC3_RAM_READ, HUC3_RAM_WRITE:
+			return uint32(addr-RAM_BANK_START) + 0x2000*uint32(h.ramBank&h.lastRamBank), true
+		case HUC3_RESPONSE:
+			return uint32(h.command<<4 | h.response), false
+		case HUC3_SEMAPHORE:
+			return 0xFF, false // always ready, commands complete immediately
+		case HUC3_IR:
+			return HUC_IR_NO_LIGHT, false
+		}
+	}
+
+	return 0xFF, false
+}
+
+func (h *HuC3) takeStateChanged() bool {
+	changed := h.clockSet
+	h.clockSet = false
+	return changed
+}
+
+func (h *HuC3) Write(addr uint16, data byte) (uint32, bool) {
+	if addr <= RAM_ENABLE_END {
+		h.mode = data & 0x0F
+	} else if addr <= ROM_BANK_SELECT_END {
+		h.romBank = data & 0x7F
+	} else if addr <= RAM_BANK_SELECT_END {
+		h.ramBank = data & 0x03
+	} else if addr >= RAM_BANK_START && addr <= RAM_BANK_END {
+		switch h.mode {
+		case HUC3_RAM_WRITE:
+			return uint32(addr-RAM_BANK_START) + 0x2000*uint32(h.ramBank&h.lastRamBank), true
+		case HUC3_COMMAND:
+			h.runCommand(data>>4&7, data&0x0F)
+		}
+	}
+
+	return 0, false
+}
+
+func (h *HuC3) runCommand(command byte, arg byte) {
+	h.command = command
+
+	switch command {
+	case huc3ReadNext:
+		h.response = h.memory[h.address]
+		h.address++
+	case huc3WriteNext:
+		h.memory[h.address] = arg
+		h.address++
+	case huc3SetAddrLow:
+		h.address = h.address&0xF0 | arg
+	case huc3SetAddrHigh:
+		h.address = h.address&0x0F | arg<<4
+	case huc3Extended:
+		switch arg {
+		case huc3LatchClock:
+			setNibbles(h.memory[0:3], h.clock.Minutes)
+			setNibbles(h.memory[3:6], h.clock.Days)
+		case huc3SetClock:
+			h.clock.Minutes = getNibbles(h.memory[0:3]) % rtc.MINUTES_PER_DAY
+			h.clock.Days = getNibbles(h.memory[3:6])
+			h.clockSet = true
+		case huc3Status:
+			h.response = 1
+		}
+		// the tone generator (0xE) isn't emulated
+	}
+}
+
+// setNibbles stores a value across consecutive nibbles, lowest first
+func setNibbles(memory []byte, val uint16) {
+	for i := range memory {
+		memory[i] = byte(val>>(i*4)) & 0x0F
+	}
+}
+
+func getNibbles(memory []byte) uint16 {
+	var val uint16 = 0
+	for i := range memory {
+		val |= uint16(memory[i]&0x0F) << (i * 4)
+	}
+	return val
+}
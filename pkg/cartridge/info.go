@@ -0,0 +1,88 @@
+package cartridge
+
+import "fmt"
+
+// Info is a decoded and checked header, for showing to the user rather than running the game
+type Info struct {
+	Title               string `json:"title"`
+	ManufacturerCode    string `json:"manufacturerCode"`
+	Licensee            string `json:"licensee"`
+	OldLicenceCode      byte   `json:"oldLicenceCode"`
+	NewLicenceCode      string `json:"newLicenceCode,omitempty"`
+	Destination         string `json:"destination"`
+	CGB                 string `json:"cgb"`
+	SGB                 bool   `json:"sgb"`
+	CartType            byte   `json:"cartType"`
+	Mapper              string `json:"mapper"`
+	RomSize             uint32 `json:"romSize"`
+	RomBanks            uint16 `json:"romBanks"`
+	RamSize             uint32 `json:"ramSize"`
+	RamBanks            byte   `json:"ramBanks"`
+	FileSize            int    `json:"fileSize"`
+	Version             byte   `json:"version"`
+	HeaderChecksum      byte   `json:"headerChecksum"`
+	HeaderChecksumValid bool   `json:"headerChecksumValid"`
+	GlobalChecksum      uint16 `json:"globalChecksum"`
+	GlobalChecksumValid bool   `json:"globalChecksumValid"`
+	LogoValid           bool   `json:"logoValid"`
+}
+
+// GetInfo decodes a ROM's header without loading it, so broken headers are reported instead of rejected
+func GetInfo(file []byte) (*Info, error) {
+	if len(file) < HEADER_START+HEADER_END {
+		return nil, fmt.Errorf("file is too small to have a header: %d bytes", len(file))
+	}
+
+	unlicensed := detectUnlicensed(file)
+	headerData := file[HEADER_START:]
+	if unlicensed == SACHEN_MMC1 || unlicensed == SACHEN_MMC2 {
+		headerData = unscrambleHeader(file)
+	}
+	header := NewHeader(headerData)
+
+	licensee, err := header.GetLicensee()
+	if err != nil {
+		licensee = "unknown"
+	}
+
+	newLicenceCode := ""
+	if header.OldLicenceCode == USE_NEW_LIC_CODE {
+		newLicenceCode = header.LicenceCode
+	}
+
+	cgb := "none"
+	if header.CGBFlag {
+		cgb = "required"
+	} else if header.CGBSupport {
+		cgb = "supported"
+	}
+
+	mapper := getCartTypeName(header.CartType)
+	if name, ok := unlicensedNames[unlicensed]; ok {
+		mapper = name
+	}
+
+	return &Info{
+		Title:               header.Title,
+		ManufacturerCode:    header.ManCode,
+		Licensee:            licensee,
+		OldLicenceCode:      header.OldLicenceCode,
+		NewLicenceCode:      newLicenceCode,
+		Destination:         header.GetDestination(),
+		CGB:                 cgb,
+		SGB:                 header.SGBFlag && header.OldLicenceCode == USE_NEW_LIC_CODE,
+		CartType:            header.CartType,
+		Mapper:              mapper,
+		RomSize:             header.RomSize.Size,
+		RomBanks:            header.RomSize.NumBanks,
+		RamSize:             header.RamSize.Size,
+		RamBanks:            header.RamSize.NumBanks,
+		FileSize:            len(file),
+		Version:             header.RomVersion,
+		HeaderChecksum:      header.HeaderChecksum,
+		HeaderChecksumValid: verifyChecksum(header.HeaderChecksum, headerData[TITLE:HEADER_CHECKSUM]) == nil,
+		GlobalChecksum:      header.GlobalChecksum,
+		GlobalChecksumValid: verifyGlobalChecksum(header.GlobalChecksum, file) == nil,
+		LogoValid:           header.LogoValid,
+	}, nil
+}
@@ -0,0 +1,25 @@
+package cartridge
+
+const (
+	LI_CHENG_IGNORE_START = 0x2101
+	LI_CHENG_IGNORE_END   = 0x2FFF
+)
+
+// LiCheng is a bootleg MBC5 that doesn't respond to ROM bank writes above 0x2100
+type LiCheng struct {
+	*MBC5
+}
+
+func NewLiCheng(romInfo RomInfo, ramInfo RamInfo) *LiCheng {
+	return &LiCheng{
+		MBC5: NewMBC5(romInfo, ramInfo, false),
+	}
+}
+
+func (l *LiCheng) Write(addr uint16, data byte) (uint32, bool) {
+	if addr >= LI_CHENG_IGNORE_START && addr <= LI_CHENG_IGNORE_END {
+		return 0, false
+	}
+
+	return l.MBC5.Write(addr, data)
+}
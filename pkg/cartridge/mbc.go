@@ -27,3 +27,10 @@ type MBC interface {
 	Read(addr uint16) (uint32, bool)
 	Write(addr uint16, data byte) (uint32, bool)
 }
+
+// stateMBC is a mapper with saved state of its own, like a clock or an EEPROM, that writes change
+// without handing back a RAM address
+type stateMBC interface {
+	// takeStateChanged reports whether the saved state has changed since it was last asked
+	takeStateChanged() bool
+}
@@ -0,0 +1,54 @@
+package cartridge
+
+const (
+	MBC2_REGISTER_END = 0x3FFF
+	MBC2_RAM_SIZE     = 0x200
+
+	// MBC2 RAM is only 4 bits wide, the upper half of each byte is open bus
+	MBC2_RAM_OPEN_BITS = 0xF0
+)
+
+type MBC2 struct {
+	ramEnabled  bool
+	romBank     byte
+	lastRomBank uint16
+}
+
+func NewMBC2(romInfo RomInfo) *MBC2 {
+	return &MBC2{
+		ramEnabled:  false,
+		romBank:     1,
+		lastRomBank: romInfo.NumBanks - 1, // max number of banks is 16
+	}
+}
+
+func (m *MBC2) Read(addr uint16) (uint32, bool) {
+	if addr <= LOWER_ROM_BANK_END {
+		return uint32(addr), true
+	} else if addr >= UPPER_ROM_BANK_START && addr <= UPPER_ROM_BANK_END {
+		return uint32(addr-UPPER_ROM_BANK_START) + uint32(uint16(m.romBank)&m.lastRomBank)*0x4000, true
+	} else if addr >= RAM_BANK_START && addr <= RAM_BANK_END && m.ramEnabled {
+		// the 512 half-bytes repeat across the whole RAM area
+		return uint32(addr & (MBC2_RAM_SIZE - 1)), true
+	}
+
+	return 0xFF, false
+}
+
+func (m *MBC2) Write(addr uint16, data byte) (uint32, bool) {
+	if addr <= MBC2_REGISTER_END {
+		// bit 8 of the address selects between the RAM enable and ROM bank registers
+		if addr&0x100 == 0 {
+			m.ramEnabled = data&0x0F == 0x0A
+		} else {
+			m.romBank = data & 0x0F
+			if m.romBank == 0 {
+				m.romBank = 1
+			}
+		}
+	} else if addr >= RAM_BANK_START && addr <= RAM_BANK_END && m.ramEnabled {
+		return uint32(addr & (MBC2_RAM_SIZE - 1)), true
+	}
+
+	return 0, false
+}
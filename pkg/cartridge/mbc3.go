@@ -8,30 +8,57 @@ const (
 	RAM_BANK_ADDR    = 0x5FFF
 	LATCH_CLOCK_ADDR = 0x7FFF
 	RTC_REG_ADDR     = 0xBFFF
+
+	RTC_FIRST_REG = 0x08
+	RTC_LAST_REG  = 0x0C
+
+	MBC3_ROM_BANK_MASK  = 0x7F
+	MBC30_ROM_BANK_MASK = 0xFF
+
+	// anything bigger needs an MBC30
+	MBC3_MAX_ROM_BANKS = 128
+	MBC3_MAX_RAM_BANKS = 4
 )
 
+// MBC3 also covers the MBC30, which has an 8 bit ROM bank number and 8 RAM banks instead of 4
 type MBC3 struct {
 	ramEnabled  bool
+	hasRam      bool
 	romBank     byte
-	lastRomBank byte
+	romBankMask byte
+	lastRomBank uint16
 	ramBank     byte
 	lastRamBank byte
 	rtcActive   bool
 	regToRead   byte
 	prevWrite   byte
+	rtcChanged  bool
 
 	rtcState *rtc.State
 }
 
-func NewMBC3(romInfo RomInfo, ramInfo RamInfo, rtcState *rtc.State) *MBC3 {
+func NewMBC3(romInfo RomInfo, ramInfo RamInfo, rtcState *rtc.State, isMBC30 bool) *MBC3 {
+	var lastRamBank byte = 0
+	if ramInfo.NumBanks > 0 {
+		lastRamBank = ramInfo.NumBanks - 1
+	}
+
+	var romBankMask byte = MBC3_ROM_BANK_MASK
+	if isMBC30 {
+		romBankMask = MBC30_ROM_BANK_MASK
+	}
+
 	return &MBC3{
-		ramEnabled:  ramInfo.Size > 0,
-		romBank:     0,
-		lastRomBank: byte(romInfo.NumBanks - 1), // max number of banks is 128
+		ramEnabled:  false,
+		hasRam:      ramInfo.Size > 0,
+		romBank:     1,
+		romBankMask: romBankMask,
+		lastRomBank: romInfo.NumBanks - 1,
 		ramBank:     0,
-		lastRamBank: ramInfo.NumBanks - 1,
+		lastRamBank: lastRamBank,
 		rtcActive:   false,
 		regToRead:   0,
+		rtcChanged:  false,
 		rtcState:    rtcState,
 	}
 }
@@ -40,49 +67,54 @@ func (m *MBC3) Read(addr uint16) (uint32, bool) {
 	if addr <= LOWER_ROM_BANK_END {
 		return uint32(addr), true
 	} else if addr >= UPPER_ROM_BANK_START && addr <= UPPER_ROM_BANK_END {
-		return uint32(addr-0x4000) + uint32(m.romBank&m.lastRomBank)*0x4000, true
+		return uint32(addr-0x4000) + uint32(uint16(m.romBank)&m.lastRomBank)*0x4000, true
 	} else if addr >= RAM_BANK_START && addr <= RAM_BANK_END {
 		if !m.ramEnabled {
 			return 0xFF, false
 		}
 
 		if m.rtcActive {
-			m.rtcState.UpdateRTC()
 			if m.rtcState.IsLatched {
 				return uint32(m.rtcState.Latched.GetRegisterValue(m.regToRead)), false
 			}
 			return uint32(m.rtcState.Unlatched.GetRegisterValue(m.regToRead)), false
 		}
 
+		if !m.hasRam {
+			return 0xFF, false
+		}
+
 		return uint32(addr-RAM_BANK_START) + 0x2000*uint32(m.ramBank&m.lastRamBank), true
 	}
 
 	return 0xFF, false
 }
 
+func (m *MBC3) takeStateChanged() bool {
+	changed := m.rtcChanged
+	m.rtcChanged = false
+	return changed
+}
+
 func (m *MBC3) Write(addr uint16, data byte) (uint32, bool) {
 
 	if addr <= RAM_ENABLE_END {
 		m.ramEnabled = data&0x0F == 0x0A
 	} else if addr <= ROM_BANK_SELECT_END {
-		m.romBank = data & 0x7F
+		m.romBank = data & m.romBankMask
 		if m.romBank == 0 {
 			m.romBank = 1
 		}
 	} else if addr <= RAM_BANK_ADDR {
-		m.rtcActive = data&8 == 8
+		m.rtcActive = data >= RTC_FIRST_REG && data <= RTC_LAST_REG
 		m.ramBank = (data & 7) & m.lastRamBank
 		if m.rtcActive {
 			m.regToRead = data
 		}
 	} else if addr <= LATCH_CLOCK_ADDR {
+		// writing 0 then 1 copies the running clock into the registers the game reads
 		if data == 1 && m.prevWrite == 0 {
-			m.rtcState.UpdateRTC()
-			m.rtcState.IsLatched = !m.rtcState.IsLatched
-
-			if m.rtcState.IsLatched {
-				m.rtcState.Latch()
-			}
+			m.rtcState.Latch()
 		}
 		m.prevWrite = data
 	} else if addr <= RTC_REG_ADDR {
@@ -91,8 +123,9 @@ func (m *MBC3) Write(addr uint16, data byte) (uint32, bool) {
 		}
 
 		if m.rtcActive {
-			m.rtcState.WriteToUnlatched(data)
-		} else {
+			m.rtcState.WriteToUnlatched(m.regToRead, data)
+			m.rtcChanged = true
+		} else if m.hasRam {
 			return uint32(addr-RAM_BANK_START) + 0x2000*uint32(m.ramBank&m.lastRamBank), true
 		}
 	}
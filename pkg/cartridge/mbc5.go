@@ -0,0 +1,87 @@
+package cartridge
+
+const (
+	ROM_BANK_LOW_END  = 0x2FFF
+	ROM_BANK_HIGH_END = 0x3FFF
+
+	RUMBLE_MOTOR_BIT = 0x08
+)
+
+type MBC5 struct {
+	ramEnabled  bool
+	hasRam      bool
+	romBank     uint16
+	lastRomBank uint16
+	ramBank     byte
+	lastRamBank byte
+	hasRumble   bool
+	motorOn     bool
+
+	// called whenever a rumble cartridge turns its motor on or off
+	onRumble func(on bool)
+}
+
+func NewMBC5(romInfo RomInfo, ramInfo RamInfo, hasRumble bool) *MBC5 {
+	var lastRamBank byte = 0
+	if ramInfo.NumBanks > 0 {
+		lastRamBank = ramInfo.NumBanks - 1
+	}
+
+	return &MBC5{
+		ramEnabled:  false,
+		hasRam:      ramInfo.Size > 0,
+		romBank:     1,
+		lastRomBank: romInfo.NumBanks - 1, // up to 512 banks
+		ramBank:     0,
+		lastRamBank: lastRamBank,
+		hasRumble:   hasRumble,
+		motorOn:     false,
+		onRumble:    nil,
+	}
+}
+
+func (m *MBC5) Read(addr uint16) (uint32, bool) {
+	if addr <= LOWER_ROM_BANK_END {
+		return uint32(addr), true
+	} else if addr >= UPPER_ROM_BANK_START && addr <= UPPER_ROM_BANK_END {
+		// unlike the other MBCs, bank 0 can be mapped here
+		return uint32(addr-UPPER_ROM_BANK_START) + uint32(m.romBank&m.lastRomBank)*0x4000, true
+	} else if addr >= RAM_BANK_START && addr <= RAM_BANK_END && m.hasRam && m.ramEnabled {
+		return uint32(addr-RAM_BANK_START) + 0x2000*uint32(m.ramBank&m.lastRamBank), true
+	}
+
+	return 0xFF, false
+}
+
+func (m *MBC5) Write(addr uint16, data byte) (uint32, bool) {
+	if addr <= RAM_ENABLE_END {
+		m.ramEnabled = data&0x0F == 0x0A
+	} else if addr <= ROM_BANK_LOW_END {
+		m.romBank = m.romBank&0x100 | uint16(data)
+	} else if addr <= ROM_BANK_HIGH_END {
+		m.romBank = uint16(data&1)<<8 | m.romBank&0xFF
+	} else if addr <= RAM_BANK_SELECT_END {
+		if m.hasRumble {
+			// the motor is wired to bit 3, leaving 3 bits for the RAM bank
+			m.setMotor(data&RUMBLE_MOTOR_BIT == RUMBLE_MOTOR_BIT)
+			m.ramBank = data & 0x07
+		} else {
+			m.ramBank = data & 0x0F
+		}
+	} else if addr >= RAM_BANK_START && addr <= RAM_BANK_END && m.hasRam && m.ramEnabled {
+		return uint32(addr-RAM_BANK_START) + 0x2000*uint32(m.ramBank&m.lastRamBank), true
+	}
+
+	return 0, false
+}
+
+func (m *MBC5) setMotor(on bool) {
+	if on == m.motorOn {
+		return
+	}
+
+	m.motorOn = on
+	if m.onRumble != nil {
+		m.onRumble(on)
+	}
+}
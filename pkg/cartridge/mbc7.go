@@ -0,0 +1,122 @@
+package cartridge
+
+const (
+	MBC7_RAM_ENABLE_2_END = 0x5FFF
+	MBC7_REGISTER_END     = 0xAFFF
+	MBC7_EEPROM_SIZE      = 0x100
+
+	// the accelerometer reads 0x81D0 when level, and moves about 0x70 per g
+	ACCEL_CENTRE      = 0x81D0
+	ACCEL_RANGE       = 0x70
+	ACCEL_ERASED      = 0x8000
+	ACCEL_ERASE_VALUE = 0x55
+	ACCEL_LATCH_VALUE = 0xAA
+)
+
+const (
+	accelErase = iota
+	accelLatch
+	accelXLow
+	accelXHigh
+	accelYLow
+	accelYHigh
+	accelZero
+	accelFF
+	eepromReg
+)
+
+type MBC7 struct {
+	ramEnabled1 bool
+	ramEnabled2 bool
+	romBank     byte
+	lastRomBank uint16
+
+	tiltX       float64
+	tiltY       float64
+	latchedX    uint16
+	latchedY    uint16
+	latchErased bool
+
+	eeprom *eeprom
+}
+
+func NewMBC7(romInfo RomInfo) *MBC7 {
+	return &MBC7{
+		ramEnabled1: false,
+		ramEnabled2: false,
+		romBank:     1,
+		lastRomBank: romInfo.NumBanks - 1,
+		tiltX:       0,
+		tiltY:       0,
+		latchedX:    ACCEL_ERASED,
+		latchedY:    ACCEL_ERASED,
+		latchErased: false,
+		eeprom:      newEeprom(),
+	}
+}
+
+// SetTilt sets how far the cartridge is tilted on each axis, from -1 to 1. Positive values tilt right and down.
+func (m *MBC7) SetTilt(x float64, y float64) {
+	m.tiltX = max(-1, min(1, x))
+	m.tiltY = max(-1, min(1, y))
+}
+
+func (m *MBC7) Read(addr uint16) (uint32, bool) {
+	if addr <= LOWER_ROM_BANK_END {
+		return uint32(addr), true
+	} else if addr >= UPPER_ROM_BANK_START && addr <= UPPER_ROM_BANK_END {
+		return uint32(addr-UPPER_ROM_BANK_START) + uint32(uint16(m.romBank)&m.lastRomBank)*0x4000, true
+	} else if addr >= RAM_BANK_START && addr <= MBC7_REGISTER_END && m.ramEnabled1 && m.ramEnabled2 {
+		switch addr >> 4 & 0xF {
+		case accelXLow:
+			return uint32(m.latchedX & 0xFF), false
+		case accelXHigh:
+			return uint32(m.latchedX >> 8), false
+		case accelYLow:
+			return uint32(m.latchedY & 0xFF), false
+		case accelYHigh:
+			return uint32(m.latchedY >> 8), false
+		case accelZero:
+			return 0x00, false
+		case eepromReg:
+			return uint32(m.eeprom.read()), false
+		}
+	}
+
+	return 0xFF, false
+}
+
+func (m *MBC7) takeStateChanged() bool {
+	changed := m.eeprom.changed
+	m.eeprom.changed = false
+	return changed
+}
+
+func (m *MBC7) Write(addr uint16, data byte) (uint32, bool) {
+	if addr <= RAM_ENABLE_END {
+		m.ramEnabled1 = data&0x0F == 0x0A
+	} else if addr <= ROM_BANK_SELECT_END {
+		m.romBank = data
+	} else if addr <= MBC7_RAM_ENABLE_2_END {
+		m.ramEnabled2 = data == 0x40
+	} else if addr >= RAM_BANK_START && addr <= MBC7_REGISTER_END && m.ramEnabled1 && m.ramEnabled2 {
+		switch addr >> 4 & 0xF {
+		case accelErase:
+			if data == ACCEL_ERASE_VALUE {
+				m.latchedX = ACCEL_ERASED
+				m.latchedY = ACCEL_ERASED
+				m.latchErased = true
+			}
+		case accelLatch:
+			if data == ACCEL_LATCH_VALUE && m.latchErased {
+				m.latchedX = uint16(ACCEL_CENTRE + int(m.tiltX*ACCEL_RANGE))
+				m.latchedY = uint16(ACCEL_CENTRE + int(m.tiltY*ACCEL_RANGE))
+				m.latchErased = false
+			}
+		case eepromReg:
+			m.eeprom.write(data)
+		}
+	}
+
+	return 0, false
+}
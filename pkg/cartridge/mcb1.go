@@ -1,33 +1,54 @@
 package cartridge
 
+import "bytes"
+
 const (
 	SimpleBankMode = iota
 	RamBankMode
 )
 
+const (
+	MULTICART_SIZE       = 0x100000
+	MULTICART_GAME_SIZE  = 0x40000
+	MULTICART_BANK_SHIFT = 4
+	DEFAULT_BANK_SHIFT   = 5
+	MULTICART_MIN_LOGOS  = 2
+	MULTICART_LOWER_MASK = 0x0F
+	DEFAULT_LOWER_MASK   = 0x1F
+)
+
 type MBC1 struct {
 	ramEnabled      bool
 	lowerRomBankNum byte
 	upperRomBankNum byte
-	lastRomBank     byte
+	lastRomBank     uint16
 	hasRam          bool
-	ramBankNum      byte
+	lastRamBank     byte
 	is1MBRom        bool
 	is32kRam        bool
 	bankSelectMode  byte
+
+	// MBC1M compilation carts wire the upper bank bits one position lower
+	isMulticart bool
 }
 
 func NewMBC1(romInfo RomInfo, ramInfo RamInfo) *MBC1 {
+	var lastRamBank byte = 0
+	if ramInfo.NumBanks > 0 {
+		lastRamBank = ramInfo.NumBanks - 1
+	}
+
 	return &MBC1{
 		ramEnabled:      false,
 		lowerRomBankNum: 0,
 		upperRomBankNum: 0,
-		lastRomBank:     byte(romInfo.NumBanks - 1),
+		lastRomBank:     romInfo.NumBanks - 1,
 		hasRam:          ramInfo.Size > 0,
-		ramBankNum:      0,
+		lastRamBank:     lastRamBank,
 		is1MBRom:        romInfo.Size >= 0x100000,
 		is32kRam:        ramInfo.Size >= 0x8000,
 		bankSelectMode:  0,
+		isMulticart:     false,
 	}
 }
 
@@ -37,24 +58,23 @@ func (mbc1 *MBC1) Read(addr uint16) (uint32, bool) {
 	} else if addr >= UPPER_ROM_BANK_START && addr <= UPPER_ROM_BANK_END {
 		return uint32(addr-0x4000) + uint32(mbc1.getUpperRomBank())*0x4000, true
 	} else if mbc1.hasRam && mbc1.ramEnabled && addr >= RAM_BANK_START && addr <= RAM_BANK_END {
-		return uint32(addr) + uint32(mbc1.getRamBank())*0x2000, true
+		return uint32(addr-RAM_BANK_START) + uint32(mbc1.getRamBank())*0x2000, true
 	}
 
-	return 0, true
+	return 0xFF, false
 }
+
 func (mbc1 *MBC1) Write(addr uint16, data byte) (uint32, bool) {
 	if addr <= RAM_ENABLE_END {
-		if data&0x0A == 0x0A {
-			mbc1.ramEnabled = true
-		} else {
-			mbc1.ramEnabled = false
-		}
+		mbc1.ramEnabled = data&0x0F == 0x0A
 	} else if addr >= ROM_BANK_SELECT_START && addr <= ROM_BANK_SELECT_END {
 		mbc1.lowerRomBankNum = data & 0x1F
 	} else if addr >= RAM_BANK_SELECT_START && addr <= RAM_BANK_SELECT_END {
 		mbc1.upperRomBankNum = data & 0x03
 	} else if addr >= UPPER_BANK_SELECT_START && addr <= UPPER_BANK_SELECT_END {
-		mbc1.bankSelectMode = data & 0x02
+		mbc1.bankSelectMode = data & 0x01
+	} else if mbc1.hasRam && mbc1.ramEnabled && addr >= RAM_BANK_START && addr <= RAM_BANK_END {
+		return uint32(addr-RAM_BANK_START) + uint32(mbc1.getRamBank())*0x2000, true
 	}
 
 	return 0, false
@@ -65,23 +85,53 @@ func (mbc1 *MBC1) getRamBank() byte {
 		return 0
 	}
 
-	return mbc1.upperRomBankNum
+	return mbc1.upperRomBankNum & mbc1.lastRamBank
 }
 
-func (mbc1 *MBC1) getUpperRomBank() byte {
+func (mbc1 *MBC1) getUpperRomBank() uint16 {
 	bank := mbc1.lowerRomBankNum
 	if bank == 0 {
 		bank = 1
 	}
 
-	return bank & mbc1.lastRomBank
+	// the upper bits are always used here, even in simple banking mode
+	shift, mask := mbc1.getBankWiring()
+	bank = bank&mask | mbc1.upperRomBankNum<<shift
+
+	return uint16(bank) & mbc1.lastRomBank
 }
 
-func (mbc1 *MBC1) getLowerRomBank() byte {
+func (mbc1 *MBC1) getLowerRomBank() uint16 {
 	if mbc1.bankSelectMode == SimpleBankMode || !mbc1.is1MBRom {
 		return 0
 	}
 
-	bank := mbc1.upperRomBankNum << 5
-	return bank & mbc1.lastRomBank
+	shift, _ := mbc1.getBankWiring()
+	bank := mbc1.upperRomBankNum << shift
+	return uint16(bank) & mbc1.lastRomBank
+}
+
+func (mbc1 *MBC1) getBankWiring() (byte, byte) {
+	if mbc1.isMulticart {
+		return MULTICART_BANK_SHIFT, MULTICART_LOWER_MASK
+	}
+	return DEFAULT_BANK_SHIFT, DEFAULT_LOWER_MASK
+}
+
+// isMulticart looks for the Nintendo logo at the start of each 256 KiB game in a 1 MiB ROM.
+// Regular MBC1 games only have the logo in the first header.
+func isMulticart(rom []byte) bool {
+	if len(rom) != MULTICART_SIZE {
+		return false
+	}
+
+	logos := 0
+	for start := 0; start < len(rom); start += MULTICART_GAME_SIZE {
+		logoStart := start + HEADER_START + LOGO
+		if bytes.Equal(rom[logoStart:logoStart+len(LogoBytes)], LogoBytes) {
+			logos++
+		}
+	}
+
+	return logos >= MULTICART_MIN_LOGOS
 }
@@ -0,0 +1,80 @@
+package cartridge
+
+import "bytes"
+
+const (
+	NT_MULTICART_REG_START = 0x5000
+	NT_MULTICART_BASE      = 0x01
+	NT_MULTICART_SIZE      = 0x02
+
+	NT_MULTICART_GAME_ALIGN = 0x8000 // games start on 32 KiB boundaries
+	NT_MULTICART_MIN_LOGOS  = 3      // the menu and at least two games
+)
+
+// outer bank sizes written to the size register, in 16 KiB banks
+var ntMulticartSizes = map[byte]uint16{
+	0x00: 32,
+	0x08: 16,
+	0x0C: 8,
+	0x0E: 4,
+	0x0F: 2,
+}
+
+// NtMulticart is the pirate multicart mapper sold under the NT label. The menu writes the game's start, in 32 KiB steps,
+// to 0x5001 and its size to 0x5002, and the game then runs on an MBC5 confined to that slice of the ROM.
+// Writes to 0x4000-0x4FFF still select the RAM bank.
+type NtMulticart struct {
+	*MBC5
+	baseBank  uint16
+	bankCount uint16
+}
+
+func NewNtMulticart(romInfo RomInfo, ramInfo RamInfo) *NtMulticart {
+	return &NtMulticart{
+		MBC5:      NewMBC5(romInfo, ramInfo, false),
+		baseBank:  0,
+		bankCount: 32,
+	}
+}
+
+func (n *NtMulticart) Read(addr uint16) (uint32, bool) {
+	if addr <= LOWER_ROM_BANK_END {
+		return uint32(addr) + uint32(n.baseBank&n.lastRomBank)*0x4000, true
+	} else if addr >= UPPER_ROM_BANK_START && addr <= UPPER_ROM_BANK_END {
+		bank := n.baseBank + n.romBank&(n.bankCount-1)
+		return uint32(addr-UPPER_ROM_BANK_START) + uint32(bank&n.lastRomBank)*0x4000, true
+	}
+
+	return n.MBC5.Read(addr)
+}
+
+func (n *NtMulticart) Write(addr uint16, data byte) (uint32, bool) {
+	if addr&0xF000 != NT_MULTICART_REG_START {
+		return n.MBC5.Write(addr, data)
+	}
+
+	// the other registers in this range flip bank bits for a few menus, which isn't emulated
+	switch addr & 3 {
+	case NT_MULTICART_BASE:
+		n.baseBank = uint16(data&0x3F) * 2
+	case NT_MULTICART_SIZE:
+		if count, ok := ntMulticartSizes[data&0x0F]; ok {
+			n.bankCount = count
+		}
+	}
+
+	return 0, false
+}
+
+// isNtMulticart looks for the headers of the games after the menu's, on the 32 KiB boundaries the mapper can start a game at
+func isNtMulticart(rom []byte) bool {
+	logos := 0
+	for start := 0; start+NT_MULTICART_GAME_ALIGN <= len(rom); start += NT_MULTICART_GAME_ALIGN {
+		logoStart := start + HEADER_START + LOGO
+		if bytes.Equal(rom[logoStart:logoStart+len(LogoBytes)], LogoBytes) {
+			logos++
+		}
+	}
+
+	return logos >= NT_MULTICART_MIN_LOGOS
+}
@@ -0,0 +1,64 @@
+package rtc
+
+import "time"
+
+const (
+	MINUTES_PER_DAY   = 1440
+	CYCLES_PER_MINUTE = CYCLES_PER_SECOND * 60
+	HUC3_DAY_MASK     = 0xFFF
+)
+
+// HuC3Clock counts minutes and days rather than the MBC3's seconds, minutes, hours and days.
+// Like State, it is advanced by emulated cycles.
+type HuC3Clock struct {
+	Minutes uint16
+	Days    uint16
+	cycles  uint64
+}
+
+type HuC3Snapshot struct {
+	Minutes   uint16
+	Days      uint16
+	Timestamp int64
+}
+
+func NewHuC3Clock() *HuC3Clock {
+	return &HuC3Clock{
+		Minutes: 0,
+		Days:    0,
+		cycles:  0,
+	}
+}
+
+func (c *HuC3Clock) AddCycles(cycles byte) {
+	c.cycles += uint64(cycles)
+	for c.cycles >= CYCLES_PER_MINUTE {
+		c.cycles -= CYCLES_PER_MINUTE
+		c.addMinutes(1)
+	}
+}
+
+func (c *HuC3Clock) addMinutes(minutes uint64) {
+	total := uint64(c.Minutes) + minutes
+	c.Days = uint16((uint64(c.Days) + total/MINUTES_PER_DAY) & HUC3_DAY_MASK)
+	c.Minutes = uint16(total % MINUTES_PER_DAY)
+}
+
+func (c *HuC3Clock) GetSnapshot() *HuC3Snapshot {
+	return &HuC3Snapshot{
+		Minutes:   c.Minutes,
+		Days:      c.Days,
+		Timestamp: time.Now().Unix(),
+	}
+}
+
+// FromSnapshot restores the clock, adding on the time that passed while the emulator wasn't running
+func (c *HuC3Clock) FromSnapshot(snapshot *HuC3Snapshot) {
+	c.Minutes = snapshot.Minutes % MINUTES_PER_DAY
+	c.Days = snapshot.Days & HUC3_DAY_MASK
+
+	elapsed := time.Since(time.Unix(snapshot.Timestamp, 0))
+	if elapsed > 0 {
+		c.addMinutes(uint64(elapsed / time.Minute))
+	}
+}
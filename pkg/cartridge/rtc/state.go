@@ -4,12 +4,27 @@ import "time"
 
 const CYCLES_PER_SECOND = 4194304
 
+const (
+	SECONDS_PER_MINUTE = 60
+	MINUTES_PER_HOUR   = 60
+	HOURS_PER_DAY      = 24
+	SECONDS_PER_DAY    = SECONDS_PER_MINUTE * MINUTES_PER_HOUR * HOURS_PER_DAY
+
+	// the day counter is 9 bits, with the top bit in DH
+	DAY_COUNTER_SIZE = 512
+
+	DH_DAY_HIGH  = 0x01
+	DH_HALT      = 0x40
+	DH_DAY_CARRY = 0x80
+)
+
+// State is ticked by emulated cycles while the game runs, so it keeps pace with the emulation even when
+// it runs fast or slow. Time that passes while the emulator is closed is added when a save is loaded.
 type State struct {
 	Unlatched *registers
 	Latched   *registers
 	IsLatched bool
 	cycles    uint64
-	lastTime  time.Time
 }
 
 type StateSnapshot struct {
@@ -44,55 +59,86 @@ func NewState() *State {
 		},
 		IsLatched: false,
 		cycles:    0,
-		lastTime:  time.Now(),
 	}
 }
 
 func (s *State) AddCycles(cycles byte) {
+	if s.Unlatched.DH&DH_HALT == DH_HALT {
+		return
+	}
+
 	s.cycles += uint64(cycles)
+	for s.cycles >= CYCLES_PER_SECOND {
+		s.cycles -= CYCLES_PER_SECOND
+		s.tick()
+	}
 }
 
-func (s *State) UpdateRTC() {
-	if s.Unlatched.DH&0x40 == 0x40 { // halt flag
-		s.cycles = 0
+// tick advances the clock by one second. Each counter only carries when it reaches its normal limit,
+// so a value written out of range counts up to the top of its bits and wraps to 0 without carrying.
+func (s *State) tick() {
+	r := s.Unlatched
+
+	r.S = (r.S + 1) & 0x3F
+	if r.S != SECONDS_PER_MINUTE {
 		return
 	}
+	r.S = 0
 
-	for s.cycles >= CYCLES_PER_SECOND {
-		s.cycles -= CYCLES_PER_SECOND
-		now := time.Now()
+	r.M = (r.M + 1) & 0x3F
+	if r.M != MINUTES_PER_HOUR {
+		return
+	}
+	r.M = 0
 
-		s.Unlatched.S += byte((now.Second() - s.lastTime.Second()) & 59)
-		if now.Second() < s.lastTime.Second() {
-			s.Unlatched.M++
-		}
+	r.H = (r.H + 1) & 0x1F
+	if r.H != HOURS_PER_DAY {
+		return
+	}
+	r.H = 0
+
+	s.addDays(1)
+}
 
-		s.Unlatched.M += byte((now.Minute() - s.lastTime.Minute()) & 59)
-		if now.Minute() < s.lastTime.Minute() {
-			s.Unlatched.H++
-		}
+func (s *State) addDays(days uint64) {
+	r := s.Unlatched
 
-		s.Unlatched.H += byte((now.Hour() - s.lastTime.Hour()) & 23)
-		if now.Hour() < s.lastTime.Hour() {
-			s.Unlatched.DL++
-		}
+	total := (uint64(r.DL) | uint64(r.DH&DH_DAY_HIGH)<<8) + days
+	if total >= DAY_COUNTER_SIZE {
+		// the carry flag stays set until the game clears it
+		r.DH |= DH_DAY_CARRY
+		total %= DAY_COUNTER_SIZE
+	}
 
-		if (s.Unlatched.DL == 0 || s.Unlatched.DL > byte(now.YearDay()&255)) && s.Unlatched.DH&1 == 1 { // day overflow
-			s.Unlatched.DH |= 0x80
-		}
+	r.DL = byte(total)
+	r.DH = r.DH&^DH_DAY_HIGH | byte(total>>8)&DH_DAY_HIGH
+}
 
-		s.Unlatched.DL += byte((now.YearDay() - s.lastTime.YearDay()) & 255)
-		s.Unlatched.DH = (s.Unlatched.DH & 0xC0) | byte(now.YearDay()>>7&1)
+// addSeconds catches the clock up by a long stretch of time without ticking through every second
+func (s *State) addSeconds(seconds uint64) {
+	r := s.Unlatched
 
-		s.lastTime = now
+	// tick through out of range values until the counters are back in their normal ranges
+	for seconds > 0 && (r.S >= SECONDS_PER_MINUTE || r.M >= MINUTES_PER_HOUR || r.H >= HOURS_PER_DAY) {
+		s.tick()
+		seconds--
+	}
+
+	total := uint64(r.S) + uint64(r.M)*SECONDS_PER_MINUTE + uint64(r.H)*SECONDS_PER_MINUTE*MINUTES_PER_HOUR + seconds
+	r.S = byte(total % SECONDS_PER_MINUTE)
+	r.M = byte(total / SECONDS_PER_MINUTE % MINUTES_PER_HOUR)
+	r.H = byte(total / (SECONDS_PER_MINUTE * MINUTES_PER_HOUR) % HOURS_PER_DAY)
+
+	if days := total / SECONDS_PER_DAY; days > 0 {
+		s.addDays(days)
 	}
 }
 
-func (s *State) WriteToUnlatched(val byte) {
-	switch val {
+func (s *State) WriteToUnlatched(reg byte, val byte) {
+	switch reg {
 	case 0x08:
 		s.Unlatched.S = val & 0x3F
-		s.cycles = 0
+		s.cycles = 0 // writing the seconds resets the sub-second counter
 	case 0x09:
 		s.Unlatched.M = val & 0x3F
 	case 0x0A:
@@ -100,12 +146,9 @@ func (s *State) WriteToUnlatched(val byte) {
 	case 0x0B:
 		s.Unlatched.DL = val
 	case 0x0C:
-		if val>>6&1 == 1 && s.Unlatched.DH>>6&1 == 1 { // RTC enabled
-			s.lastTime = time.Now()
-		}
-		s.Unlatched.DH = val & 0xC1
+		s.Unlatched.DH = val & (DH_DAY_HIGH | DH_HALT | DH_DAY_CARRY)
 	default:
-		panic("unrecognized value: " + string(val))
+		panic("unrecognized value: " + string(reg))
 	}
 }
 
@@ -130,10 +173,12 @@ func (s *State) GetSnapshot() *StateSnapshot {
 		LatchedHours:    s.Latched.H,
 		LatchedDaysLow:  s.Latched.DL,
 		LatchedDaysHigh: s.Latched.DH,
-		Timestamp:       s.lastTime.Unix(),
+		Timestamp:       time.Now().Unix(),
 	}
 }
 
+// FromSnapshot restores the clock, then runs it forward by the real time since the snapshot was taken,
+// unless the game had halted it
 func (s *State) FromSnapshot(snapshot *StateSnapshot) {
 	s.Unlatched.S = snapshot.Seconds
 	s.Unlatched.M = snapshot.Minutes
@@ -145,5 +190,10 @@ func (s *State) FromSnapshot(snapshot *StateSnapshot) {
 	s.Latched.H = snapshot.LatchedHours
 	s.Latched.DL = snapshot.LatchedDaysLow
 	s.Latched.DH = snapshot.LatchedDaysHigh
-	s.lastTime = time.Unix(snapshot.Timestamp, 0)
+	s.cycles = 0
+
+	elapsed := time.Now().Unix() - snapshot.Timestamp
+	if elapsed > 0 && s.Unlatched.DH&DH_HALT == 0 {
+		s.addSeconds(uint64(elapsed))
+	}
 }
@@ -0,0 +1,100 @@
+package cartridge
+
+const (
+	SACHEN_BANK_REGISTER_UNLOCK = 0x30
+	SACHEN_LOCK_READS           = 0x31
+)
+
+// the header is only readable through the scrambled address lines while the mapper is locked
+const (
+	sachenLockedPlain = iota // MMC2 only, passes the DMG boot ROM's logo check
+	sachenLockedHigh         // A7 is forced high, pointing the boot ROM at the real logo
+	sachenUnlocked
+)
+
+// Sachen covers the Sachen MMC1 and MMC2. Both scramble reads of 0x0100-0x01FF so that the Nintendo logo
+// isn't stored where a licensed game's would be, and both unlock once the boot ROM has read the logo.
+// The base ROM bank and bank mask can only be changed while bits 4 and 5 of the ROM bank are set.
+type Sachen struct {
+	isMMC2      bool
+	lockState   byte
+	lockReads   byte
+	baseBank    byte
+	bankMask    byte
+	romBank     byte
+	lastRomBank uint16
+}
+
+func NewSachen(romInfo RomInfo, isMMC2 bool) *Sachen {
+	return &Sachen{
+		isMMC2:      isMMC2,
+		lockState:   sachenUnlocked, // the boot ROM has already run, unless lock is called
+		lockReads:   0,
+		baseBank:    0,
+		bankMask:    0,
+		romBank:     1,
+		lastRomBank: romInfo.NumBanks - 1,
+	}
+}
+
+// lock puts the mapper back into its power-on state, for when a boot ROM is run
+func (s *Sachen) lock() {
+	s.lockState = sachenLockedHigh
+	if s.isMMC2 {
+		s.lockState = sachenLockedPlain
+	}
+	s.lockReads = 0
+}
+
+func (s *Sachen) Read(addr uint16) (uint32, bool) {
+	if addr <= LOWER_ROM_BANK_END {
+		if addr&0xFF00 == HEADER_START {
+			if s.lockState != sachenUnlocked {
+				s.lockReads++
+				if s.lockReads == SACHEN_LOCK_READS {
+					s.lockState++
+					s.lockReads = 0
+				}
+			}
+			if s.lockState == sachenLockedHigh {
+				addr |= 0x80
+			}
+			addr = unscrambleSachen(addr)
+		}
+		return uint32(addr) + uint32(uint16(s.baseBank&s.bankMask)&s.lastRomBank)*0x4000, true
+	} else if addr >= UPPER_ROM_BANK_START && addr <= UPPER_ROM_BANK_END {
+		bank := s.baseBank&s.bankMask | s.romBank&^s.bankMask
+		return uint32(addr-UPPER_ROM_BANK_START) + uint32(uint16(bank)&s.lastRomBank)*0x4000, true
+	}
+
+	return 0xFF, false
+}
+
+func (s *Sachen) Write(addr uint16, data byte) (uint32, bool) {
+	if addr <= RAM_ENABLE_END {
+		if s.romBank&SACHEN_BANK_REGISTER_UNLOCK == SACHEN_BANK_REGISTER_UNLOCK {
+			s.baseBank = data
+		}
+	} else if addr <= ROM_BANK_SELECT_END {
+		s.romBank = data
+		if s.romBank == 0 {
+			s.romBank = 1
+		}
+	} else if addr <= RAM_BANK_SELECT_END {
+		if s.romBank&SACHEN_BANK_REGISTER_UNLOCK == SACHEN_BANK_REGISTER_UNLOCK {
+			s.bankMask = data
+		}
+	}
+
+	return 0, false
+}
+
+// unscrambleSachen swaps address lines A0 with A6, and A1 with A4
+func unscrambleSachen(addr uint16) uint16 {
+	unscrambled := addr & 0xFFAC
+	unscrambled |= (addr & 0x40) >> 6
+	unscrambled |= (addr & 0x10) >> 3
+	unscrambled |= (addr & 0x02) << 3
+	unscrambled |= (addr & 0x01) << 6
+	return unscrambled
+}
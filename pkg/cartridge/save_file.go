@@ -0,0 +1,136 @@
+package cartridge
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/siliconandsolder/go-boy/pkg/cartridge/rtc"
+)
+
+// Battery saves are raw RAM, the same as flash carts and other emulators use. MBC3 clocks are appended in the
+// footer shared by VBA, BGB, SameBoy and mGBA: the live and latched registers as 32 bit little endian values,
+// followed by a unix timestamp that is 64 bits long in newer saves and 32 bits in older ones.
+const (
+	RTC_FOOTER_SIZE       = 48
+	RTC_FOOTER_SIZE_32BIT = 44
+	RTC_FOOTER_REGISTERS  = 10
+
+	// goboy's own footer for HuC3 clocks: minutes, days, then a 64 bit unix timestamp
+	HUC3_FOOTER_SIZE = 12
+
+	// every RAM size is a multiple of MBC2's 512 bytes, so anything left over is a footer
+	SAVE_SIZE_ALIGNMENT = 512
+)
+
+type SaveFile struct {
+	Sram         []byte
+	RtcSnapshot  *rtc.StateSnapshot
+	HuC3Snapshot *rtc.HuC3Snapshot `json:",omitempty"`
+}
+
+// EncodeRawSave writes the RAM, followed by a footer for any clock
+func EncodeRawSave(save *SaveFile) []byte {
+	data := append([]byte{}, save.Sram...)
+
+	if save.RtcSnapshot != nil {
+		snapshot := save.RtcSnapshot
+		registers := []byte{
+			snapshot.Seconds, snapshot.Minutes, snapshot.Hours, snapshot.DaysLow, snapshot.DaysHigh,
+			snapshot.LatchedSeconds, snapshot.LatchedMinutes, snapshot.LatchedHours, snapshot.LatchedDaysLow, snapshot.LatchedDaysHigh,
+		}
+		for _, val := range registers {
+			data = binary.LittleEndian.AppendUint32(data, uint32(val))
+		}
+		data = binary.LittleEndian.AppendUint64(data, uint64(snapshot.Timestamp))
+	} else if save.HuC3Snapshot != nil {
+		data = binary.LittleEndian.AppendUint16(data, save.HuC3Snapshot.Minutes)
+		data = binary.LittleEndian.AppendUint16(data, save.HuC3Snapshot.Days)
+		data = binary.LittleEndian.AppendUint64(data, uint64(save.HuC3Snapshot.Timestamp))
+	}
+
+	return data
+}
+
+// EncodeJsonSave writes the format goboy used before raw saves
+func EncodeJsonSave(save *SaveFile) ([]byte, error) {
+	return json.Marshal(save)
+}
+
+// DecodeSave reads either format, reporting whether it was the old JSON one. If ramSize is negative,
+// the size of the RAM is worked out from the length of the file.
+func DecodeSave(data []byte, ramSize int) (*SaveFile, bool, error) {
+	if json.Valid(data) {
+		save := &SaveFile{}
+		if err := json.Unmarshal(data, save); err != nil {
+			return nil, true, err
+		}
+		return save, true, nil
+	}
+
+	if ramSize < 0 {
+		ramSize = len(data) - len(data)%SAVE_SIZE_ALIGNMENT
+		// an MBC7's EEPROM is smaller than the alignment and never has a footer, so anything that isn't one is all EEPROM
+		if ramSize == 0 && !isFooterSize(len(data)) {
+			ramSize = len(data)
+		}
+	}
+	if ramSize > len(data) {
+		return nil, false, fmt.Errorf("save is too small: %d bytes, expected %d", len(data), ramSize)
+	}
+
+	save := &SaveFile{
+		Sram:         data[:ramSize],
+		RtcSnapshot:  nil,
+		HuC3Snapshot: nil,
+	}
+
+	footer := data[ramSize:]
+	switch len(footer) {
+	case 0:
+		break
+	case RTC_FOOTER_SIZE, RTC_FOOTER_SIZE_32BIT:
+		save.RtcSnapshot = decodeRtcFooter(footer)
+	case HUC3_FOOTER_SIZE:
+		save.HuC3Snapshot = &rtc.HuC3Snapshot{
+			Minutes:   binary.LittleEndian.Uint16(footer[0:]),
+			Days:      binary.LittleEndian.Uint16(footer[2:]),
+			Timestamp: int64(binary.LittleEndian.Uint64(footer[4:])),
+		}
+	default:
+		return nil, false, fmt.Errorf("unrecognised save footer: %d bytes", len(footer))
+	}
+
+	return save, false, nil
+}
+
+func isFooterSize(size int) bool {
+	return size == RTC_FOOTER_SIZE || size == RTC_FOOTER_SIZE_32BIT || size == HUC3_FOOTER_SIZE
+}
+
+func decodeRtcFooter(footer []byte) *rtc.StateSnapshot {
+	registers := make([]byte, RTC_FOOTER_REGISTERS)
+	for i := range registers {
+		registers[i] = byte(binary.LittleEndian.Uint32(footer[i*4:]))
+	}
+
+	var timestamp int64
+	if len(footer) == RTC_FOOTER_SIZE {
+		timestamp = int64(binary.LittleEndian.Uint64(footer[RTC_FOOTER_REGISTERS*4:]))
+	} else {
+		timestamp = int64(binary.LittleEndian.Uint32(footer[RTC_FOOTER_REGISTERS*4:]))
+	}
+
+	return &rtc.StateSnapshot{
+		Seconds:         registers[0],
+		Minutes:         registers[1],
+		Hours:           registers[2],
+		DaysLow:         registers[3],
+		DaysHigh:        registers[4],
+		LatchedSeconds:  registers[5],
+		LatchedMinutes:  registers[6],
+		LatchedHours:    registers[7],
+		LatchedDaysLow:  registers[8],
+		LatchedDaysHigh: registers[9],
+		Timestamp:       timestamp,
+	}
+}
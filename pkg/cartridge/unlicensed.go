@@ -0,0 +1,110 @@
+package cartridge
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// mapper names for NewCartridgeWithMapper
+const (
+	MAPPER_AUTO  = "auto"
+	WISDOM_TREE  = "wisdomtree"
+	SACHEN_MMC1  = "sachen1"
+	SACHEN_MMC2  = "sachen2"
+	LI_CHENG     = "licheng"
+	NT_MULTICART = "ntmulticart"
+)
+
+// forcing a licensed mapper stands in a cartridge type with every feature of that mapper
+var forcedCartTypes = map[string]byte{
+	"rom":    ROM_ONLY,
+	"mbc1":   0x03,
+	"mbc2":   0x06,
+	"mbc3":   0x10,
+	"mbc5":   0x1B,
+	"mbc7":   MBC_7,
+	"camera": POCKET_CAMERA,
+	"huc1":   HUC_1,
+	"huc3":   HUC_3,
+}
+
+var wisdomTreeNames = [][]byte{[]byte("WISDOM TREE"), []byte("WISDOM\x00TREE")}
+
+func isUnlicensedMapper(name string) bool {
+	return name == WISDOM_TREE || name == SACHEN_MMC1 || name == SACHEN_MMC2 || name == LI_CHENG || name == NT_MULTICART
+}
+
+// CheckMapperName reports whether NewCartridgeWithMapper knows a mapper name
+func CheckMapperName(name string) error {
+	if _, ok := forcedCartTypes[name]; ok || name == MAPPER_AUTO || isUnlicensedMapper(name) {
+		return nil
+	}
+	return fmt.Errorf("unknown mapper: %s", name)
+}
+
+// detectUnlicensed returns the name of the unlicensed mapper a ROM needs, or an empty string if the header can be trusted
+func detectUnlicensed(rom []byte) string {
+	logoStart := HEADER_START + LOGO
+	if bytes.Equal(rom[logoStart:logoStart+len(LogoBytes)], LogoBytes) {
+		// Wisdom Tree games claim to be ROM only, but are bigger than 32 KiB
+		if rom[HEADER_START+CART_TYPE] == ROM_ONLY && len(rom) > WISDOM_TREE_BANK_SIZE {
+			for _, name := range wisdomTreeNames {
+				if bytes.Contains(rom[:WISDOM_TREE_BANK_SIZE], name) {
+					return WISDOM_TREE
+				}
+			}
+		}
+
+		// the multicart passes for an MBC5, but gives itself away with the headers of the games after the menu.
+		// The Li Cheng can't be told from a licensed MBC5 that way, so it has to be forced.
+		if cartType := rom[HEADER_START+CART_TYPE]; cartType >= MBC_5_START && cartType <= MBC_5_END && isNtMulticart(rom) {
+			return NT_MULTICART
+		}
+		return ""
+	}
+
+	// the logo is in the place the boot ROM is sent to while A7 is held high, which only the Sachen mappers do.
+	// The MMC2 also has a second copy for its first, unaltered, pass.
+	if hasScrambledLogo(rom, 0x80) {
+		if hasScrambledLogo(rom, 0) {
+			return SACHEN_MMC2
+		}
+		return SACHEN_MMC1
+	}
+
+	return ""
+}
+
+func hasScrambledLogo(rom []byte, addrBits uint16) bool {
+	for i, val := range LogoBytes {
+		addr := unscrambleSachen(uint16(HEADER_START+LOGO+i) | addrBits)
+		if int(addr) >= len(rom) || rom[addr] != val {
+			return false
+		}
+	}
+	return true
+}
+
+// unscrambleHeader reads the header the way a Sachen mapper presents it once unlocked
+func unscrambleHeader(rom []byte) []byte {
+	header := make([]byte, 0x100)
+	for i := range header {
+		header[i] = rom[unscrambleSachen(uint16(HEADER_START+i))]
+	}
+	return header
+}
+
+func getUnlicensedMBC(name string, header *Header) MBC {
+	switch name {
+	case WISDOM_TREE:
+		return NewWisdomTree(header.RomSize)
+	case SACHEN_MMC1:
+		return NewSachen(header.RomSize, false)
+	case SACHEN_MMC2:
+		return NewSachen(header.RomSize, true)
+	case NT_MULTICART:
+		return NewNtMulticart(header.RomSize, header.RamSize)
+	default:
+		return NewLiCheng(header.RomSize, header.RamSize)
+	}
+}
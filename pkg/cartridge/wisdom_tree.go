@@ -0,0 +1,33 @@
+package cartridge
+
+const WISDOM_TREE_BANK_SIZE = 0x8000
+
+// WisdomTree swaps the whole 32 KiB ROM area at once. The bank number is taken from the low byte of the address written to,
+// not from the data.
+type WisdomTree struct {
+	romBank     byte
+	lastRomBank uint16
+}
+
+func NewWisdomTree(romInfo RomInfo) *WisdomTree {
+	return &WisdomTree{
+		romBank:     0,
+		lastRomBank: max(romInfo.NumBanks/2, 1) - 1,
+	}
+}
+
+func (w *WisdomTree) Read(addr uint16) (uint32, bool) {
+	if addr <= UPPER_ROM_BANK_END {
+		return uint32(addr) + uint32(uint16(w.romBank)&w.lastRomBank)*WISDOM_TREE_BANK_SIZE, true
+	}
+
+	return 0xFF, false
+}
+
+func (w *WisdomTree) Write(addr uint16, data byte) (uint32, bool) {
+	if addr <= LOWER_ROM_BANK_END {
+		w.romBank = byte(addr)
+	}
+
+	return 0, false
+}
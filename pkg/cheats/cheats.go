@@ -0,0 +1,286 @@
+package cheats
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	GAME_GENIE_SHORT_LEN = 6
+	GAME_GENIE_LONG_LEN  = 9
+	GAME_SHARK_LEN       = 8
+
+	GAME_GENIE_ADDR_XOR    = 0xF000
+	GAME_GENIE_COMPARE_XOR = 0xBA
+
+	ROM_END = 0x7FFF
+)
+
+// GameShark code types, the first byte of the code
+const (
+	SHARK_WRITE      = 0x01 // write to whatever is mapped at the address
+	SHARK_SRAM_BANK  = 0x80 // 0x80-0x8F write to cartridge RAM bank n
+	SHARK_WRAM_BANK  = 0x90 // 0x90-0x97 write to work RAM bank n
+	SHARK_BANK_MASK  = 0x0F
+	SHARK_WRAM_MASK  = 0x07
+	SHARK_TYPE_GROUP = 0xF0
+)
+
+// Memory is what GameShark codes write to each frame
+type Memory interface {
+	Write(addr uint16, value byte)
+	WriteBank(bank byte, addr uint16, value byte)
+}
+
+type Cheat struct {
+	Code        string
+	Description string
+	Enabled     bool
+
+	isGameGenie bool
+	addr        uint16
+	value       byte
+	hasCompare  bool
+	compare     byte
+	sharkType   byte
+}
+
+// Engine applies Game Genie codes to ROM reads, and GameShark codes to RAM once per frame
+type Engine struct {
+	Enabled bool
+
+	cheats []*Cheat
+
+	// enabled Game Genie codes by address, so ROM reads without a cheat stay cheap
+	romPatches map[uint16][]*Cheat
+}
+
+func NewEngine() *Engine {
+	return &Engine{
+		Enabled:    true,
+		cheats:     make([]*Cheat, 0),
+		romPatches: make(map[uint16][]*Cheat),
+	}
+}
+
+// Add parses a Game Genie (ABC-DEF or ABC-DEF-GHI) or GameShark (ttvvaaaa) code
+func (e *Engine) Add(code string, description string, enabled bool) error {
+	cheat, err := parseCheat(code)
+	if err != nil {
+		return err
+	}
+
+	cheat.Description = description
+	cheat.Enabled = enabled
+	e.cheats = append(e.cheats, cheat)
+	e.rebuild()
+	return nil
+}
+
+func (e *Engine) Remove(idx int) error {
+	if idx < 0 || idx >= len(e.cheats) {
+		return fmt.Errorf("no cheat %d", idx)
+	}
+
+	e.cheats = append(e.cheats[:idx], e.cheats[idx+1:]...)
+	e.rebuild()
+	return nil
+}
+
+func (e *Engine) SetEnabled(idx int, enabled bool) error {
+	if idx < 0 || idx >= len(e.cheats) {
+		return fmt.Errorf("no cheat %d", idx)
+	}
+
+	e.cheats[idx].Enabled = enabled
+	e.rebuild()
+	return nil
+}
+
+func (e *Engine) GetCheats() []*Cheat {
+	return e.cheats
+}
+
+func (e *Engine) rebuild() {
+	clear(e.romPatches)
+	for _, cheat := range e.cheats {
+		if cheat.Enabled && cheat.isGameGenie {
+			e.romPatches[cheat.addr] = append(e.romPatches[cheat.addr], cheat)
+		}
+	}
+}
+
+// ApplyRomRead substitutes a Game Genie value for the byte read from ROM. When the code has a compare value,
+// the substitution only happens if the original byte matches, which picks out a single ROM bank.
+func (e *Engine) ApplyRomRead(addr uint16, val byte) byte {
+	if !e.Enabled || len(e.romPatches) == 0 {
+		return val
+	}
+
+	for _, cheat := range e.romPatches[addr] {
+		if !cheat.hasCompare || cheat.compare == val {
+			return cheat.value
+		}
+	}
+	return val
+}
+
+// ApplyRamWrites runs every enabled GameShark code. It should be called once per frame.
+func (e *Engine) ApplyRamWrites(mem Memory) {
+	if !e.Enabled {
+		return
+	}
+
+	for _, cheat := range e.cheats {
+		if !cheat.Enabled || cheat.isGameGenie {
+			continue
+		}
+
+		switch cheat.sharkType & SHARK_TYPE_GROUP {
+		case SHARK_SRAM_BANK:
+			mem.WriteBank(cheat.sharkType&SHARK_BANK_MASK, cheat.addr, cheat.value)
+		case SHARK_WRAM_BANK:
+			mem.WriteBank(cheat.sharkType&SHARK_WRAM_MASK, cheat.addr, cheat.value)
+		default:
+			mem.Write(cheat.addr, cheat.value)
+		}
+	}
+}
+
+func parseCheat(code string) (*Cheat, error) {
+	digits := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+
+	switch len(digits) {
+	case GAME_GENIE_SHORT_LEN, GAME_GENIE_LONG_LEN:
+		return parseGameGenie(code, digits)
+	case GAME_SHARK_LEN:
+		return parseGameShark(code, digits)
+	default:
+		return nil, fmt.Errorf("%s is not a Game Genie or GameShark code", code)
+	}
+}
+
+// parseGameGenie decodes ABC-DEF-GHI: AB is the new value, FCDE the address with the top digit inverted,
+// and G and I the compare value, rotated and scrambled. H is unused.
+func parseGameGenie(code string, digits string) (*Cheat, error) {
+	vals, err := parseHexDigits(code, digits)
+	if err != nil {
+		return nil, err
+	}
+
+	addr := (uint16(vals[5])<<12 | uint16(vals[2])<<8 | uint16(vals[3])<<4 | uint16(vals[4])) ^ GAME_GENIE_ADDR_XOR
+	if addr > ROM_END {
+		return nil, fmt.Errorf("%s patches 0x%04X, which is outside ROM", code, addr)
+	}
+
+	cheat := &Cheat{
+		Code:        code,
+		isGameGenie: true,
+		addr:        addr,
+		value:       vals[0]<<4 | vals[1],
+		hasCompare:  len(digits) == GAME_GENIE_LONG_LEN,
+		compare:     0,
+	}
+
+	if cheat.hasCompare {
+		compare := vals[6]<<4 | vals[8]
+		compare = compare>>2 | compare<<6
+		cheat.compare = compare ^ GAME_GENIE_COMPARE_XOR
+	}
+
+	return cheat, nil
+}
+
+// parseGameShark decodes ttvvaaaa, with the address in little endian order
+func parseGameShark(code string, digits string) (*Cheat, error) {
+	raw, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a valid GameShark code: %w", code, err)
+	}
+
+	sharkType := byte(raw >> 24)
+	if sharkType != SHARK_WRITE && sharkType&SHARK_TYPE_GROUP != SHARK_SRAM_BANK && sharkType&SHARK_TYPE_GROUP != SHARK_WRAM_BANK {
+		return nil, fmt.Errorf("%s has an unknown GameShark code type: %02X", code, sharkType)
+	}
+
+	return &Cheat{
+		Code:      code,
+		addr:      uint16(raw&0xFF)<<8 | uint16(raw>>8&0xFF),
+		value:     byte(raw >> 16),
+		sharkType: sharkType,
+	}, nil
+}
+
+func parseHexDigits(code string, digits string) ([]byte, error) {
+	vals := make([]byte, len(digits))
+	for i, digit := range digits {
+		val, err := strconv.ParseUint(string(digit), 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a valid Game Genie code", code)
+		}
+		vals[i] = byte(val)
+	}
+	return vals, nil
+}
+
+type cheatFile struct {
+	Code        string
+	Description string
+	Enabled     bool
+}
+
+// Load replaces the cheats with those saved in a file. A missing file just means there are no cheats.
+func (e *Engine) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var saved []cheatFile
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("could not read cheats: %w", err)
+	}
+
+	// the file is read in full before anything is replaced, so a bad code keeps the cheats already loaded
+	cheats := make([]*Cheat, 0, len(saved))
+	for _, entry := range saved {
+		cheat, err := parseCheat(entry.Code)
+		if err != nil {
+			return err
+		}
+		cheat.Description = entry.Description
+		cheat.Enabled = entry.Enabled
+		cheats = append(cheats, cheat)
+	}
+
+	e.cheats = cheats
+	e.rebuild()
+	return nil
+}
+
+func (e *Engine) Save(path string) error {
+	saved := make([]cheatFile, len(e.cheats))
+	for i, cheat := range e.cheats {
+		saved[i] = cheatFile{
+			Code:        cheat.Code,
+			Description: cheat.Description,
+			Enabled:     cheat.Enabled,
+		}
+	}
+
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0666)
+}
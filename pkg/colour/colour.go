@@ -0,0 +1,15 @@
+package colour
+
+// Bgr15ToRGBA converts a 15-bit BGR colour, as used by both the CGB and the SNES, to RGBA
+func Bgr15ToRGBA(colour uint16) uint32 {
+	red := uint32(colour & 0x1F)
+	green := uint32(colour >> 5 & 0x1F)
+	blue := uint32(colour >> 10 & 0x1F)
+
+	// scale 5 bits up to 8, so 0x1F becomes 0xFF
+	red = red<<3 | red>>2
+	green = green<<3 | green>>2
+	blue = blue<<3 | blue>>2
+
+	return red<<24 | green<<16 | blue<<8 | 0xFF
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	APP_DIR     = "goboy"
+	CONFIG_FILE = "config.json"
+)
+
+type Audio struct {
+	SampleRate int `json:"sample_rate,omitempty"`
+	BufferSize int `json:"buffer_size,omitempty"` // samples handed to the audio device at a time
+}
+
+type Turbo struct {
+	Rate int    `json:"rate,omitempty"` // frames turbo holds a button down, then up, for
+	Mode string `json:"mode,omitempty"` // hold or toggle
+}
+
+// Settings holds everything the config file can set. Zero values are unset, and leave the default alone.
+type Settings struct {
+	Keys          map[string]string `json:"keys,omitempty"`    // joypad button name to SDL key name, e.g. "a": "Z"
+	Hotkeys       map[string]string `json:"hotkeys,omitempty"` // hotkey action to SDL key name, e.g. "quit": "Escape"
+	Scale         int32             `json:"scale,omitempty"`
+	Palette       string            `json:"palette,omitempty"`
+	Mapper        string            `json:"mapper,omitempty"` // set per game for dumps whose headers don't give their mapper away
+	Audio         Audio             `json:"audio"`
+	Turbo         Turbo             `json:"turbo"`
+	SaveDir       string            `json:"save_dir,omitempty"`
+	ScreenshotDir string            `json:"screenshot_dir,omitempty"`
+}
+
+// Config is the config file: settings for every game, and overrides for particular games keyed by the ROM's
+// SHA-1 hash in hex. Since the mapper can be overridden, Games doubles as the table of known unlicensed dumps.
+type Config struct {
+	Settings
+	Games map[string]Settings `json:"games,omitempty"`
+}
+
+func NewConfig() *Config {
+	return &Config{
+		Settings: Settings{
+			Keys:    make(map[string]string),
+			Hotkeys: make(map[string]string),
+		},
+		Games: make(map[string]Settings),
+	}
+}
+
+// GetDefaultPath returns the config file's path in the user's config directory, e.g. ~/.config/goboy/config.json
+// on Linux, where $XDG_CONFIG_HOME is respected
+func GetDefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, APP_DIR, CONFIG_FILE), nil
+}
+
+// Load reads a config file. A missing file is an empty config.
+func Load(path string) (*Config, error) {
+	config := NewConfig()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return config, nil
+}
+
+// Save writes the config file, creating its directory if needed
+func (c *Config) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// ForGame returns the settings for a ROM, with that game's overrides applied over the global settings
+func (c *Config) ForGame(hash string) Settings {
+	settings := c.Settings
+	settings.Keys = mergeKeys(c.Keys, nil)
+	settings.Hotkeys = mergeKeys(c.Hotkeys, nil)
+
+	game, ok := c.getGame(hash)
+	if !ok {
+		return settings
+	}
+
+	settings.Keys = mergeKeys(settings.Keys, game.Keys)
+	settings.Hotkeys = mergeKeys(settings.Hotkeys, game.Hotkeys)
+	if game.Scale != 0 {
+		settings.Scale = game.Scale
+	}
+	if game.Palette != "" {
+		settings.Palette = game.Palette
+	}
+	if game.Mapper != "" {
+		settings.Mapper = game.Mapper
+	}
+	if game.Audio.SampleRate != 0 {
+		settings.Audio.SampleRate = game.Audio.SampleRate
+	}
+	if game.Audio.BufferSize != 0 {
+		settings.Audio.BufferSize = game.Audio.BufferSize
+	}
+	if game.Turbo.Rate != 0 {
+		settings.Turbo.Rate = game.Turbo.Rate
+	}
+	if game.Turbo.Mode != "" {
+		settings.Turbo.Mode = game.Turbo.Mode
+	}
+	if game.SaveDir != "" {
+		settings.SaveDir = game.SaveDir
+	}
+	if game.ScreenshotDir != "" {
+		settings.ScreenshotDir = game.ScreenshotDir
+	}
+	return settings
+}
+
+// getGame finds a game's overrides, whether its hash was written in upper or lower case
+func (c *Config) getGame(hash string) (Settings, bool) {
+	for gameHash, game := range c.Games {
+		if strings.EqualFold(gameHash, hash) {
+			return game, true
+		}
+	}
+	return Settings{}, false
+}
+
+// mergeKeys copies base, then overrides it, so neither map is changed
+func mergeKeys(base map[string]string, overrides map[string]string) map[string]string {
+	merged := make(map[string]string)
+	for name, key := range base {
+		merged[name] = key
+	}
+	for name, key := range overrides {
+		merged[name] = key
+	}
+	return merged
+}
@@ -1,7 +1,9 @@
 package controller
 
 import (
+	"fmt"
 	"github.com/veandco/go-sdl2/sdl"
+	"strings"
 )
 
 const (
@@ -13,62 +15,192 @@ const (
 	B_BUTTON
 	SELECT
 	START
+
+	// turbo buttons can be bound to keys, and press A or B on and off while active
+	TURBO_A
+	TURBO_B
 )
 
+const NUM_BUTTONS = 8
+
+const (
+	TURBO_HOLD   = "hold"   // turbo while the key is held
+	TURBO_TOGGLE = "toggle" // each press of the key switches turbo on or off
+
+	DEFAULT_TURBO_RATE = 2
+)
+
+var buttonNames = map[string]int{
+	"right":  RIGHT,
+	"left":   LEFT,
+	"up":     UP,
+	"down":   DOWN,
+	"a":      A_BUTTON,
+	"b":      B_BUTTON,
+	"select": SELECT,
+	"start":  START,
+
+	"turbo-a": TURBO_A,
+	"turbo-b": TURBO_B,
+}
+
+// GetButton returns the button with the given name, e.g. "a", "start" or "left"
+func GetButton(name string) (int, error) {
+	button, ok := buttonNames[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("unknown button %q", name)
+	}
+	return button, nil
+}
+
+// GetDefaultKeys returns the keys each button is bound to unless configured otherwise
+func GetDefaultKeys() map[sdl.Keycode]int {
+	return map[sdl.Keycode]int{
+		sdl.K_RIGHT:  RIGHT,
+		sdl.K_LEFT:   LEFT,
+		sdl.K_UP:     UP,
+		sdl.K_DOWN:   DOWN,
+		sdl.K_z:      A_BUTTON,
+		sdl.K_x:      B_BUTTON,
+		sdl.K_RSHIFT: SELECT,
+		sdl.K_RETURN: START,
+		sdl.K_a:      TURBO_A,
+		sdl.K_s:      TURBO_B,
+	}
+}
+
+// GetButtonName returns the name GetButton takes for a button
+func GetButtonName(button int) string {
+	for name, b := range buttonNames {
+		if b == button {
+			return name
+		}
+	}
+	return ""
+}
+
 type Controller struct {
+	keys          map[sdl.Keycode]int
 	inputs        []bool
+	held          []bool // buttons held down by their own keys, which turbo leaves pressed
 	selectButtons bool
 	selectDPad    bool
+
+	turboRate   int
+	turboMode   string
+	turboActive []bool // indexed from TURBO_A
+	turboFrames []int  // frames since each turbo button became active
 }
 
 func NewController() *Controller {
 	return &Controller{
-		inputs:        make([]bool, 8),
+		keys:          GetDefaultKeys(),
+		inputs:        make([]bool, NUM_BUTTONS),
+		held:          make([]bool, NUM_BUTTONS),
 		selectButtons: true,
 		selectDPad:    true,
+		turboRate:     DEFAULT_TURBO_RATE,
+		turboMode:     TURBO_HOLD,
+		turboActive:   make([]bool, 2),
+		turboFrames:   make([]int, 2),
 	}
 }
 
+// CheckJoypadEvent presses or releases the button bound to a key. Key repeats should be left out, since every
+// event is taken as a new press.
 func (c *Controller) CheckJoypadEvent(keyCode sdl.Keycode, state uint8) {
-	if state == sdl.PRESSED {
-		switch keyCode {
-		case sdl.K_RIGHT:
-			c.inputs[RIGHT] = true
-		case sdl.K_LEFT:
-			c.inputs[LEFT] = true
-		case sdl.K_UP:
-			c.inputs[UP] = true
-		case sdl.K_DOWN:
-			c.inputs[DOWN] = true
-		case sdl.K_z:
-			c.inputs[A_BUTTON] = true
-		case sdl.K_x:
-			c.inputs[B_BUTTON] = true
-		case sdl.K_RSHIFT:
-			c.inputs[SELECT] = true
-		case sdl.K_RETURN:
-			c.inputs[START] = true
+	button, ok := c.keys[keyCode]
+	if !ok {
+		return
+	}
+
+	pressed := state == sdl.PRESSED
+	if button == TURBO_A || button == TURBO_B {
+		c.checkTurboEvent(button, pressed)
+		return
+	}
+
+	c.held[button] = pressed
+	c.inputs[button] = pressed
+}
+
+// SetTurbo sets how many frames turbo holds a button down, and then up, for, and whether turbo keys are held or toggled
+func (c *Controller) SetTurbo(rate int, mode string) error {
+	if rate < 1 {
+		return fmt.Errorf("turbo rate must be at least one frame")
+	}
+	if mode != TURBO_HOLD && mode != TURBO_TOGGLE {
+		return fmt.Errorf("unknown turbo mode %q: expected hold or toggle", mode)
+	}
+
+	c.turboRate = rate
+	c.turboMode = mode
+	return nil
+}
+
+func (c *Controller) checkTurboEvent(turbo int, pressed bool) {
+	active := pressed
+	if c.turboMode == TURBO_TOGGLE {
+		if !pressed {
+			return // releases don't count
 		}
-	} else {
-		switch keyCode {
-		case sdl.K_RIGHT:
-			c.inputs[RIGHT] = false
-		case sdl.K_LEFT:
-			c.inputs[LEFT] = false
-		case sdl.K_UP:
-			c.inputs[UP] = false
-		case sdl.K_DOWN:
-			c.inputs[DOWN] = false
-		case sdl.K_z:
-			c.inputs[A_BUTTON] = false
-		case sdl.K_x:
-			c.inputs[B_BUTTON] = false
-		case sdl.K_RSHIFT:
-			c.inputs[SELECT] = false
-		case sdl.K_RETURN:
-			c.inputs[START] = false
+		active = !c.turboActive[turbo-TURBO_A]
+	}
+
+	c.setTurboActive(turbo, active)
+}
+
+func (c *Controller) setTurboActive(turbo int, active bool) {
+	idx := turbo - TURBO_A
+	if active == c.turboActive[idx] {
+		return
+	}
+	c.turboActive[idx] = active
+	c.turboFrames[idx] = 0
+
+	if !active {
+		button := getTurboButton(turbo)
+		c.inputs[button] = c.held[button]
+	}
+}
+
+// UpdateTurbo presses or releases the buttons under active turbo keys, and must be called once a frame.
+// Turbo always starts pressed and counts frames rather than time, so the same input gives the same presses.
+func (c *Controller) UpdateTurbo() {
+	for idx, active := range c.turboActive {
+		if !active {
+			continue
 		}
+
+		button := getTurboButton(TURBO_A + idx)
+		on := (c.turboFrames[idx]/c.turboRate)%2 == 0
+		c.inputs[button] = c.held[button] || on
+		c.turboFrames[idx]++
+	}
+}
+
+func getTurboButton(turbo int) int {
+	if turbo == TURBO_A {
+		return A_BUTTON
 	}
+	return B_BUTTON
+}
+
+// SetKeys replaces the key bindings with a map of keys to the buttons they press
+func (c *Controller) SetKeys(keys map[sdl.Keycode]int) {
+	c.keys = keys
+}
+
+// SetInput presses or releases a button without a key event, for playing back recorded input.
+// Turbo buttons act as if their keys were held, whatever the turbo mode.
+func (c *Controller) SetInput(button int, pressed bool) {
+	if button == TURBO_A || button == TURBO_B {
+		c.setTurboActive(button, pressed)
+		return
+	}
+
+	c.held[button] = pressed
+	c.inputs[button] = pressed
 }
 
 func (c *Controller) CheckForInputs() bool {
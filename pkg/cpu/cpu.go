@@ -52,13 +52,12 @@ func NewCpu(bus *bus.Bus, manager *interrupts.Manager, timer *SysTimer) *Cpu {
 	bc := NewRegister()
 	de := NewRegister()
 	hl := NewRegister()
-	af.lower.value = 0x01
-	bc.upper.value = 0x00
-	bc.lower.value = 0x13
-	de.upper.value = 0x00
-	de.lower.value = 0xd8
-	hl.upper.value = 0x01
-	hl.lower.value = 0x4d
+
+	afVal, bcVal, deVal, hlVal := bus.GetModel().GetInitialRegisters(bus.IsCGB(), bus.GetHeaderChecksum())
+	af.setAll(afVal)
+	bc.setAll(bcVal)
+	de.setAll(deVal)
+	hl.setAll(hlVal)
 
 	return &Cpu{
 		AF:               af,
@@ -77,6 +76,18 @@ func NewCpu(bus *bus.Bus, manager *interrupts.Manager, timer *SysTimer) *Cpu {
 	}
 }
 
+// ResetForBootRom clears the registers so execution starts at the beginning of the boot ROM,
+// instead of at the cartridge entry point with the values the boot ROM would have left behind
+func (cpu *Cpu) ResetForBootRom() {
+	cpu.AF.setAll(0)
+	cpu.BC.setAll(0)
+	cpu.DE.setAll(0)
+	cpu.HL.setAll(0)
+	cpu.SP = 0
+	cpu.PC = 0
+	cpu.timer.systemTimer = 0
+}
+
 func (cpu *Cpu) Cycle() (byte, error) {
 
 	//if err := cpu.timer.CycleFrameSequencer(); err != nil {
@@ -90,6 +101,12 @@ func (cpu *Cpu) Cycle() (byte, error) {
 
 	cpu.waitCycles = 0
 
+	// the CPU is halted while VRAM DMA copies a block
+	if dmaCycles := cpu.bus.CycleVramDma(); dmaCycles > 0 {
+		cpu.decrementDMA(dmaCycles)
+		return dmaCycles, nil
+	}
+
 	//if cpu.dmaCountdown > 0 {
 	//	cpu.dmaCountdown--
 	//	return nil
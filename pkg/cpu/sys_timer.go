@@ -21,7 +21,7 @@ type SysTimer struct {
 func NewSysTimer(bus *bus.Bus) *SysTimer {
 	return &SysTimer{
 		bus:         bus,
-		systemTimer: 0,
+		systemTimer: bus.GetModel().GetInitialDiv(),
 		tima:        0,
 		timaTimer:   0,
 		tma:         0,
@@ -0,0 +1,124 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Model is the hardware being emulated. Games and test roms tell models apart by the register values
+// the boot rom leaves behind, so each model starts with its own values.
+type Model byte
+
+const (
+	DMG0 Model = iota
+	DMG
+	MGB
+	SGB
+	SGB2
+	CGB
+	AGB
+)
+
+var modelNames = map[Model]string{
+	DMG0: "dmg0",
+	DMG:  "dmg",
+	MGB:  "mgb",
+	SGB:  "sgb",
+	SGB2: "sgb2",
+	CGB:  "cgb",
+	AGB:  "agb",
+}
+
+func (m Model) String() string {
+	return modelNames[m]
+}
+
+// Parse reads a model name such as "dmg" or "cgb"
+func Parse(name string) (Model, error) {
+	for m, modelName := range modelNames {
+		if strings.EqualFold(name, modelName) {
+			return m, nil
+		}
+	}
+
+	return DMG, fmt.Errorf("unknown model: %s", name)
+}
+
+// Detect picks the model a cartridge was made for, from its header flags
+func Detect(cgbFlag bool, sgbFlag bool) Model {
+	if cgbFlag {
+		return CGB
+	} else if sgbFlag {
+		return SGB
+	}
+	return DMG
+}
+
+// IsCGB reports whether the model has the CGB's colour hardware. A DMG game still runs in compatibility mode.
+func (m Model) IsCGB() bool {
+	return m == CGB || m == AGB
+}
+
+func (m Model) IsSGB() bool {
+	return m == SGB || m == SGB2
+}
+
+// GetInitialRegisters returns AF, BC, DE and HL as the boot rom leaves them
+func (m Model) GetInitialRegisters(cgbMode bool, headerChecksum byte) (uint16, uint16, uint16, uint16) {
+	// the DMG boot rom leaves H and C set unless the header checksum is 0
+	var dmgFlags uint16 = 0x80
+	if headerChecksum != 0 {
+		dmgFlags = 0xB0
+	}
+
+	switch m {
+	case DMG0:
+		return 0x0100, 0xFF13, 0x00C1, 0x8403
+	case MGB:
+		return 0xFF00 | dmgFlags, 0x0013, 0x00D8, 0x014D
+	case SGB:
+		return 0x0100, 0x0014, 0x0000, 0xC060
+	case SGB2:
+		return 0xFF00, 0x0014, 0x0000, 0xC060
+	case CGB:
+		if cgbMode {
+			return 0x1180, 0x0000, 0xFF56, 0x000D
+		}
+		return 0x1180, 0x0000, 0x0008, 0x007C
+	case AGB:
+		if cgbMode {
+			return 0x1100, 0x0100, 0xFF56, 0x000D
+		}
+		return 0x1100, 0x0100, 0x0008, 0x007C
+	default:
+		return 0x0100 | dmgFlags, 0x0013, 0x00D8, 0x014D
+	}
+}
+
+// GetInitialDiv returns the internal divider counter when the boot rom hands over to the game.
+// DIV (FF04) is its upper byte.
+func (m Model) GetInitialDiv() uint16 {
+	switch m {
+	case DMG0:
+		return 0x1830
+	case DMG, MGB:
+		return 0xABCC
+	case CGB, AGB:
+		return 0x267C
+	default:
+		return 0x0000 // the SGB's boot rom takes a variable amount of time
+	}
+}
+
+// GetInitialStat returns the value of STAT (FF41) when the game starts
+func (m Model) GetInitialStat() byte {
+	if m == DMG0 {
+		return 0x81
+	}
+	return 0x85
+}
+
+// HasBootSound reports whether channel 1 was left on by the boot rom's chime. The SGB has no chime.
+func (m Model) HasBootSound() bool {
+	return !m.IsSGB()
+}
@@ -0,0 +1,103 @@
+package movie
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/siliconandsolder/go-boy/pkg/controller"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Movie is recorded input, one set of held buttons per frame.
+//
+// Movie files are plain text. Each line holds buttons for a number of frames, e.g. "30 a+right" holds A and right
+// for 30 frames, and "60 -" or just "60" holds nothing for a second. Blank lines and lines starting with # are skipped.
+// Turbo buttons are held like turbo keys, and press their button at the controller's turbo rate.
+type Movie struct {
+	frames []uint16 // a bit per button, indexed like the controller's buttons
+}
+
+func NewMovie() *Movie {
+	return &Movie{
+		frames: make([]uint16, 0),
+	}
+}
+
+// Load reads a movie file. An empty path gives an empty movie.
+func Load(path string) (*Movie, error) {
+	m := NewMovie()
+	if path == "" {
+		return m, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if err := m.addLine(line); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNum, err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *Movie) addLine(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) > 2 {
+		return fmt.Errorf("expected a frame count and buttons, got %q", line)
+	}
+
+	count, err := strconv.Atoi(fields[0])
+	if err != nil || count < 1 {
+		return fmt.Errorf("invalid frame count %q", fields[0])
+	}
+
+	var buttons uint16 = 0
+	if len(fields) == 2 && fields[1] != "-" {
+		for _, name := range strings.Split(fields[1], "+") {
+			button, err := controller.GetButton(name)
+			if err != nil {
+				return err
+			}
+			buttons |= 1 << button
+		}
+	}
+
+	for i := 0; i < count; i++ {
+		m.frames = append(m.frames, buttons)
+	}
+	return nil
+}
+
+// Length returns the number of frames in the movie
+func (m *Movie) Length() int {
+	return len(m.frames)
+}
+
+// Apply holds the buttons recorded for a frame. Frames past the end of the movie hold nothing.
+func (m *Movie) Apply(frame int, ctrl *controller.Controller) {
+	var buttons uint16 = 0
+	if frame < len(m.frames) {
+		buttons = m.frames[frame]
+	}
+
+	for button := controller.RIGHT; button <= controller.TURBO_B; button++ {
+		ctrl.SetInput(button, buttons>>button&1 == 1)
+	}
+}
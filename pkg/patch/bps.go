@@ -0,0 +1,135 @@
+package patch
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+// BPS actions, the low two bits of each command
+const (
+	BPS_SOURCE_READ = iota // copy from the source at the same offset
+	BPS_TARGET_READ        // copy bytes out of the patch
+	BPS_SOURCE_COPY        // copy from anywhere in the source
+	BPS_TARGET_COPY        // copy from earlier in the target
+)
+
+// applyBps builds the patched ROM from the actions in the patch. The sizes and CRC32s in the patch must match
+// the ROM before and after.
+func applyBps(rom []byte, patch []byte) ([]byte, error) {
+	if len(patch) < len(bpsMagic)+FOOTER_SIZE {
+		return nil, fmt.Errorf("bps patch is truncated")
+	}
+	body := patch[:len(patch)-FOOTER_SIZE]
+	sourceCrc, targetCrc, err := checkFooter(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &patchReader{data: body, pos: len(bpsMagic)}
+	sourceSize := r.readNumber()
+	targetSize := r.readNumber()
+	metadataSize := r.readNumber()
+	if r.err != nil {
+		return nil, r.err
+	}
+	if metadataSize > uint64(len(body)-r.pos) {
+		return nil, fmt.Errorf("bps patch is truncated")
+	}
+	r.pos += int(metadataSize)
+	if sourceSize != uint64(len(rom)) || crc32.ChecksumIEEE(rom) != sourceCrc {
+		return nil, fmt.Errorf("bps patch is for a different rom")
+	}
+	if targetSize > MAX_TARGET_SIZE {
+		return nil, fmt.Errorf("bps patch makes a rom of %d bytes, more than the %d a cartridge can hold", targetSize, MAX_TARGET_SIZE)
+	}
+
+	target := make([]byte, 0, targetSize)
+	sourceOffset, targetOffset := 0, 0
+	for r.err == nil && r.pos < len(body) {
+		command := r.readNumber()
+		length := int(command>>2) + 1
+		if uint64(len(target)+length) > targetSize {
+			return nil, fmt.Errorf("bps patch writes past the end of the rom")
+		}
+
+		switch command & 3 {
+		case BPS_SOURCE_READ:
+			start := len(target)
+			if start+length > len(rom) {
+				return nil, fmt.Errorf("bps patch reads past the end of the source rom")
+			}
+			target = append(target, rom[start:start+length]...)
+		case BPS_TARGET_READ:
+			for i := 0; i < length; i++ {
+				target = append(target, r.readByte())
+			}
+		case BPS_SOURCE_COPY:
+			sourceOffset += decodeOffset(r.readNumber())
+			if sourceOffset < 0 || sourceOffset+length > len(rom) {
+				return nil, fmt.Errorf("bps patch reads past the end of the source rom")
+			}
+			target = append(target, rom[sourceOffset:sourceOffset+length]...)
+			sourceOffset += length
+		case BPS_TARGET_COPY:
+			targetOffset += decodeOffset(r.readNumber())
+			if targetOffset < 0 || targetOffset >= len(target) {
+				return nil, fmt.Errorf("bps patch copies from outside the rom")
+			}
+			// a byte at a time, since the copy can overlap what it is writing
+			for i := 0; i < length; i++ {
+				target = append(target, target[targetOffset])
+				targetOffset++
+			}
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if uint64(len(target)) != targetSize || crc32.ChecksumIEEE(target) != targetCrc {
+		return nil, fmt.Errorf("bps patch produced the wrong rom: checksum mismatch")
+	}
+	return target, nil
+}
+
+// decodeOffset turns a signed BPS offset, whose low bit is the sign, into an int
+func decodeOffset(value uint64) int {
+	offset := int(value >> 1)
+	if value&1 == 1 {
+		return -offset
+	}
+	return offset
+}
+
+// createBps writes a linear patch: runs the ROMs share are read from the source, and the rest stored in the patch
+func createBps(original []byte, modified []byte) []byte {
+	out := append([]byte{}, bpsMagic...)
+	out = encodeNumber(out, uint64(len(original)))
+	out = encodeNumber(out, uint64(len(modified)))
+	out = encodeNumber(out, 0) // no metadata
+
+	matches := func(pos int) bool {
+		return pos < len(original) && original[pos] == modified[pos]
+	}
+
+	for pos := 0; pos < len(modified); {
+		start := pos
+		if matches(pos) {
+			for pos < len(modified) && matches(pos) {
+				pos++
+			}
+			out = encodeNumber(out, uint64(pos-start-1)<<2|BPS_SOURCE_READ)
+		} else {
+			for pos < len(modified) && !matches(pos) {
+				pos++
+			}
+			out = encodeNumber(out, uint64(pos-start-1)<<2|BPS_TARGET_READ)
+			out = append(out, modified[start:pos]...)
+		}
+	}
+
+	out = binary.LittleEndian.AppendUint32(out, crc32.ChecksumIEEE(original))
+	out = binary.LittleEndian.AppendUint32(out, crc32.ChecksumIEEE(modified))
+	return binary.LittleEndian.AppendUint32(out, crc32.ChecksumIEEE(out))
+}
@@ -0,0 +1,106 @@
+package patch
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	IPS_EOF         = 0x454F46 // "EOF", which is why no record can start at this offset
+	IPS_MAX_OFFSET  = 0xFFFFFF
+	IPS_MAX_RECORD  = 0xFFFF
+	IPS_HEADER_SIZE = 5
+)
+
+// applyIps copies each record over the ROM, growing it if a record runs past the end.
+// A three-byte size after the EOF marker truncates the ROM, as some patchers write.
+func applyIps(rom []byte, patch []byte) ([]byte, error) {
+	target := make([]byte, len(rom))
+	copy(target, rom)
+
+	pos := IPS_HEADER_SIZE
+	for {
+		if pos+3 > len(patch) {
+			return nil, fmt.Errorf("ips patch is missing its EOF marker")
+		}
+		offset := int(patch[pos])<<16 | int(patch[pos+1])<<8 | int(patch[pos+2])
+		pos += 3
+		if offset == IPS_EOF {
+			break
+		}
+
+		if pos+2 > len(patch) {
+			return nil, fmt.Errorf("ips record at %06X is truncated", offset)
+		}
+		size := int(binary.BigEndian.Uint16(patch[pos:]))
+		pos += 2
+
+		var data []byte
+		if size == 0 {
+			// run-length encoded: a two-byte count and the byte to repeat
+			if pos+3 > len(patch) {
+				return nil, fmt.Errorf("ips record at %06X is truncated", offset)
+			}
+			size = int(binary.BigEndian.Uint16(patch[pos:]))
+			data = make([]byte, size)
+			for i := range data {
+				data[i] = patch[pos+2]
+			}
+			pos += 3
+		} else {
+			if pos+size > len(patch) {
+				return nil, fmt.Errorf("ips record at %06X is truncated", offset)
+			}
+			data = patch[pos : pos+size]
+			pos += size
+		}
+
+		if offset+size > len(target) {
+			target = append(target, make([]byte, offset+size-len(target))...)
+		}
+		copy(target[offset:], data)
+	}
+
+	if pos+3 <= len(patch) {
+		size := int(patch[pos])<<16 | int(patch[pos+1])<<8 | int(patch[pos+2])
+		if size < len(target) {
+			target = target[:size]
+		}
+	}
+
+	return target, nil
+}
+
+// createIps writes a record for each run of changed bytes, and a truncation size if the ROM shrank
+func createIps(original []byte, modified []byte) ([]byte, error) {
+	if len(modified) > IPS_MAX_OFFSET+1 {
+		return nil, fmt.Errorf("ips patches can't reach past 16MiB")
+	}
+
+	out := append([]byte{}, ipsMagic...)
+	for pos := 0; pos < len(modified); {
+		if pos < len(original) && original[pos] == modified[pos] {
+			pos++
+			continue
+		}
+
+		start := pos
+		if start == IPS_EOF {
+			// this offset reads as the end of the patch, so start the record a byte early
+			start--
+		}
+		for pos < len(modified) && pos-start < IPS_MAX_RECORD && (pos >= len(original) || original[pos] != modified[pos]) {
+			pos++
+		}
+
+		out = append(out, byte(start>>16), byte(start>>8), byte(start))
+		out = binary.BigEndian.AppendUint16(out, uint16(pos-start))
+		out = append(out, modified[start:pos]...)
+	}
+
+	out = append(out, "EOF"...)
+	if len(modified) < len(original) {
+		out = append(out, byte(len(modified)>>16), byte(len(modified)>>8), byte(len(modified)))
+	}
+	return out, nil
+}
@@ -0,0 +1,119 @@
+package patch
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	FORMAT_IPS = "ips"
+	FORMAT_UPS = "ups"
+	FORMAT_BPS = "bps"
+
+	// UPS and BPS patches end with CRC32s of the source, the target and the rest of the patch
+	FOOTER_SIZE = 12
+
+	// the most an MBC5 can address. Bigger target sizes are rejected before anything is allocated for them.
+	MAX_TARGET_SIZE = 0x800000
+)
+
+var ipsMagic = []byte("PATCH")
+var upsMagic = []byte("UPS1")
+var bpsMagic = []byte("BPS1")
+
+// Apply patches a ROM with an IPS, UPS or BPS patch, telling them apart by their magic bytes.
+// UPS and BPS patches are checked against the CRC32s they carry; IPS patches have none.
+func Apply(rom []byte, patch []byte) ([]byte, error) {
+	if bytes.HasPrefix(patch, ipsMagic) {
+		return applyIps(rom, patch)
+	} else if bytes.HasPrefix(patch, upsMagic) {
+		return applyUps(rom, patch)
+	} else if bytes.HasPrefix(patch, bpsMagic) {
+		return applyBps(rom, patch)
+	}
+	return nil, fmt.Errorf("not an IPS, UPS or BPS patch")
+}
+
+// Create builds a patch that turns original into modified. Only IPS and BPS patches can be created.
+func Create(format string, original []byte, modified []byte) ([]byte, error) {
+	switch strings.ToLower(format) {
+	case FORMAT_IPS:
+		return createIps(original, modified)
+	case FORMAT_BPS:
+		return createBps(original, modified), nil
+	}
+	return nil, fmt.Errorf("can't create %q patches: expected ips or bps", format)
+}
+
+// FindPatch returns the patch sitting next to a ROM with the same name, e.g. game.ips for game.gb or game.zip,
+// or an empty string if there isn't one
+func FindPatch(romPath string) string {
+	base := strings.TrimSuffix(romPath, filepath.Ext(romPath))
+	for _, format := range []string{FORMAT_IPS, FORMAT_UPS, FORMAT_BPS} {
+		path := base + "." + format
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// decodeNumber reads one of the variable-length numbers UPS and BPS patches use, returning it and the bytes it took
+func decodeNumber(data []byte) (uint64, int, error) {
+	var value uint64 = 0
+	var shift uint64 = 1
+	for i, b := range data {
+		value += uint64(b&0x7F) * shift
+		if b&0x80 != 0 {
+			return value, i + 1, nil
+		}
+		shift <<= 7
+		value += shift
+	}
+	return 0, 0, fmt.Errorf("patch ends in the middle of a number")
+}
+
+func encodeNumber(out []byte, value uint64) []byte {
+	for {
+		b := byte(value & 0x7F)
+		value >>= 7
+		if value == 0 {
+			return append(out, 0x80|b)
+		}
+		out = append(out, b)
+		value--
+	}
+}
+
+// patchReader walks through a UPS or BPS patch, remembering the first error so callers can check once
+type patchReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *patchReader) readNumber() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	value, n, err := decodeNumber(r.data[r.pos:])
+	r.pos += n
+	r.err = err
+	return value
+}
+
+func (r *patchReader) readByte() byte {
+	if r.err != nil {
+		return 0
+	}
+	if r.pos >= len(r.data) {
+		r.err = fmt.Errorf("patch is truncated")
+		return 0
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
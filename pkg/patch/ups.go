@@ -0,0 +1,68 @@
+package patch
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+// applyUps XORs each hunk into the ROM. The sizes and CRC32s in the patch must match the ROM before and after.
+func applyUps(rom []byte, patch []byte) ([]byte, error) {
+	if len(patch) < len(upsMagic)+FOOTER_SIZE {
+		return nil, fmt.Errorf("ups patch is truncated")
+	}
+	body := patch[:len(patch)-FOOTER_SIZE]
+	sourceCrc, targetCrc, err := checkFooter(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &patchReader{data: body, pos: len(upsMagic)}
+	sourceSize := r.readNumber()
+	targetSize := r.readNumber()
+	if r.err != nil {
+		return nil, r.err
+	}
+	if sourceSize != uint64(len(rom)) || crc32.ChecksumIEEE(rom) != sourceCrc {
+		return nil, fmt.Errorf("ups patch is for a different rom")
+	}
+	if targetSize > MAX_TARGET_SIZE {
+		return nil, fmt.Errorf("ups patch makes a rom of %d bytes, more than the %d a cartridge can hold", targetSize, MAX_TARGET_SIZE)
+	}
+
+	target := make([]byte, targetSize)
+	copy(target, rom)
+
+	var pos uint64 = 0
+	for r.err == nil && r.pos < len(body) {
+		pos += r.readNumber()
+		// XOR bytes in until a zero, which stands for one unchanged byte
+		for r.err == nil {
+			b := r.readByte()
+			if pos < targetSize {
+				target[pos] ^= b
+			}
+			pos++
+			if b == 0 {
+				break
+			}
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if crc32.ChecksumIEEE(target) != targetCrc {
+		return nil, fmt.Errorf("ups patch produced the wrong rom: checksum mismatch")
+	}
+	return target, nil
+}
+
+// checkFooter verifies the patch's own CRC32 and returns the ones it gives for the source and target
+func checkFooter(patch []byte) (uint32, uint32, error) {
+	footer := patch[len(patch)-FOOTER_SIZE:]
+	if crc32.ChecksumIEEE(patch[:len(patch)-4]) != binary.LittleEndian.Uint32(footer[8:]) {
+		return 0, 0, fmt.Errorf("patch is corrupt: checksum mismatch")
+	}
+	return binary.LittleEndian.Uint32(footer), binary.LittleEndian.Uint32(footer[4:]), nil
+}
@@ -11,6 +11,7 @@ type BgFetcher struct {
 	tileData []byte
 
 	tileId        byte
+	tileAttr      byte // CGB only, read from VRAM bank 1
 	mapAddr       uint16
 	tileLine      byte
 	tileOffset    int32
@@ -32,6 +33,7 @@ func newBgFetcher(bus *bus.Bus, lcdc *LcdControl, scs *ScrollStatus) *BgFetcher
 		state:         0,
 		tileData:      make([]byte, 8),
 		tileId:        0,
+		tileAttr:      0,
 		tileOffset:    0,
 		mapAddr:       0,
 		tileLine:      0,
@@ -72,6 +74,9 @@ func (f *BgFetcher) cycle(shouldCycle bool) {
 	case ReadTileID:
 		f.mapAddr = f.getTileMapBase() + uint16(f.tileY)*32 + uint16(((f.pixelX>>3)+f.fetcherX)&31)
 		f.tileId = f.bus.PpuReadVram(f.mapAddr)
+		if f.bus.IsCGB() {
+			f.tileAttr = f.bus.PpuReadVramBank(f.mapAddr, 1)
+		}
 		f.state = ReadTileData0
 		break
 	case ReadTileData0:
@@ -85,7 +90,14 @@ func (f *BgFetcher) cycle(shouldCycle bool) {
 	case PushToFIFO:
 		if f.fifo.size <= 8 {
 			for i := 7; i >= 0; i-- {
-				if err := f.fifo.push(newPixel(f.tileData[i], 0xFF47, 0)); err != nil {
+				bitPos := i
+				if f.tileAttr>>5&1 == 1 { // x flip
+					bitPos = 7 - i
+				}
+
+				pixel := newPixel(f.tileData[bitPos], 0xFF47, f.tileAttr>>7&1)
+				pixel.cgbPalette = f.tileAttr & 7
+				if err := f.fifo.push(pixel); err != nil {
 					panic(err)
 				}
 			}
@@ -98,12 +110,16 @@ func (f *BgFetcher) cycle(shouldCycle bool) {
 
 func (f *BgFetcher) readTileLine(isHigh bool) {
 	// get tile data base
-	addr := f.getTileDataBase() + uint16(f.tileId)*16 + uint16(f.pixelY%8)<<1
+	line := f.pixelY % 8
+	if f.tileAttr>>6&1 == 1 { // y flip
+		line = 7 - line
+	}
+	addr := f.getTileDataBase() + uint16(f.tileId)*16 + uint16(line)<<1
 
 	if isHigh {
 		addr++
 	}
-	data := f.bus.PpuReadVram(addr)
+	data := f.bus.PpuReadVramBank(addr, f.tileAttr>>3&1)
 
 	for bitPos := byte(0); bitPos < 8; bitPos++ {
 		if isHigh {
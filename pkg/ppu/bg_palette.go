@@ -1,5 +1,7 @@
 package ppu
 
+import "github.com/siliconandsolder/go-boy/pkg/colour"
+
 func getColour(idx byte) uint32 {
 	switch idx {
 	case 0: // white
@@ -14,3 +16,8 @@ func getColour(idx byte) uint32 {
 		return 0xFF0000FF
 	}
 }
+
+// getCgbColour converts a little-endian 15-bit BGR palette entry to RGBA
+func getCgbColour(low byte, high byte) uint32 {
+	return colour.Bgr15ToRGBA(uint16(high)<<8 | uint16(low))
+}
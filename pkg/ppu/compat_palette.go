@@ -0,0 +1,165 @@
+package ppu
+
+import (
+	"fmt"
+	"github.com/siliconandsolder/go-boy/pkg/colour"
+	"strings"
+)
+
+// The CGB boot ROM colourizes DMG games by looking up a checksum of the title in a table.
+// Only games licensed by Nintendo are recognised, everything else gets the default palette.
+// Each combination picks three palettes (OBJ0, OBJ1, BG) as offsets into a flat list of colours.
+// A few of the offsets are not multiples of four, which is how the real boot ROM builds some of its odd palettes.
+
+const (
+	TITLE_CHECKSUM_LENGTH = 16
+	FIRST_DUPLICATE_IDX   = 65
+)
+
+type CompatPalette struct {
+	bg   [4]uint32
+	obj0 [4]uint32
+	obj1 [4]uint32
+}
+
+var compatColours = []uint16{
+	0x7FFF, 0x32BF, 0x00D0, 0x0000,
+	0x639F, 0x4279, 0x15B0, 0x04CB,
+	0x7FFF, 0x6E31, 0x454A, 0x0000,
+	0x7FFF, 0x1BEF, 0x0200, 0x0000,
+	0x7FFF, 0x421F, 0x1CF2, 0x0000,
+	0x7FFF, 0x5294, 0x294A, 0x0000,
+	0x7FFF, 0x03FF, 0x012F, 0x0000,
+	0x7FFF, 0x03EF, 0x01D6, 0x0000,
+	0x7FFF, 0x42B5, 0x3DC8, 0x0000,
+	0x7E74, 0x03FF, 0x0180, 0x0000,
+	0x67FF, 0x77AC, 0x1A13, 0x2D6B,
+	0x7ED6, 0x4BFF, 0x2175, 0x0000,
+	0x53FF, 0x4A5F, 0x7E52, 0x0000,
+	0x4FFF, 0x7ED2, 0x3A4C, 0x1CE0,
+	0x03ED, 0x7FFF, 0x255F, 0x0000,
+	0x036A, 0x021F, 0x03FF, 0x7FFF,
+	0x7FFF, 0x01DF, 0x0112, 0x0000,
+	0x231F, 0x035F, 0x00F2, 0x0009,
+	0x7FFF, 0x03EA, 0x011F, 0x0000,
+	0x299F, 0x001A, 0x000C, 0x0000,
+	0x7FFF, 0x027F, 0x001F, 0x0000,
+	0x7FFF, 0x03E0, 0x0206, 0x0120,
+	0x7FFF, 0x7EEB, 0x001F, 0x7C00,
+	0x7FFF, 0x3FFF, 0x7E00, 0x001F,
+	0x7FFF, 0x03FF, 0x001F, 0x0000,
+	0x03FF, 0x001F, 0x000C, 0x0000,
+	0x7FFF, 0x033F, 0x0193, 0x0000,
+	0x0000, 0x4200, 0x037F, 0x7FFF,
+	0x7FFF, 0x7E8C, 0x7C00, 0x0000,
+	0x7FFF, 0x1BEF, 0x6180, 0x0000,
+}
+
+// colour offsets for OBJ0, OBJ1 and BG
+var compatCombinations = [][3]byte{
+	{16, 16, 116}, {72, 72, 72}, {80, 80, 80}, {96, 96, 96}, {36, 36, 36},
+	{0, 0, 0}, {108, 108, 108}, {20, 20, 20}, {48, 48, 48}, {104, 104, 104},
+	{64, 32, 32}, {16, 112, 112}, {16, 8, 8}, {12, 16, 16}, {16, 116, 116},
+	{112, 16, 112}, {8, 68, 8}, {64, 64, 32}, {16, 16, 28}, {16, 16, 72},
+	{16, 16, 80}, {76, 76, 36}, {15, 15, 44}, {68, 68, 8}, {16, 16, 8},
+	{16, 16, 12}, {112, 112, 0}, {12, 12, 0}, {0, 0, 4}, {72, 88, 72},
+	{80, 88, 80}, {96, 88, 96}, {64, 88, 32}, {68, 16, 52}, {111, 0, 56},
+	{111, 16, 60}, {76, 88, 36}, {64, 112, 40}, {16, 92, 112}, {68, 88, 8},
+	{16, 0, 8}, {16, 112, 12}, {112, 12, 0}, {12, 112, 16}, {84, 112, 16},
+	{12, 112, 0}, {100, 12, 112}, {0, 112, 32}, {16, 12, 112}, {112, 12, 24},
+	{16, 112, 116},
+}
+
+var titleChecksums = []byte{
+	0x00, 0x88, 0x16, 0x36, 0xD1, 0xDB, 0xF2, 0x3C, 0x8C, 0x92, 0x3D, 0x5C, 0x58, 0xC9, 0x3E, 0x70,
+	0x1D, 0x59, 0x69, 0x19, 0x35, 0xA8, 0x14, 0xAA, 0x75, 0x95, 0x99, 0x34, 0x6F, 0x15, 0xFF, 0x97,
+	0x4B, 0x90, 0x17, 0x10, 0x39, 0xF7, 0xF6, 0xA2, 0x49, 0x4E, 0x43, 0x68, 0xE0, 0x8B, 0xF0, 0xCE,
+	0x0C, 0x29, 0xE8, 0xB7, 0x86, 0x9A, 0x52, 0x01, 0x9D, 0x71, 0x9C, 0xBD, 0x5D, 0x6D, 0x67, 0x3F,
+	0x6B,
+	// these checksums are shared by several games, so the 4th letter of the title is checked as well
+	0xB3, 0x46, 0x28, 0xA5, 0xC6, 0xD3, 0x27, 0x61, 0x18, 0x66, 0x6A, 0xBF, 0x0D, 0xF4,
+	0xB3, 0x46, 0x28, 0xA5, 0xC6, 0xD3, 0x27, 0x61, 0x18, 0x66, 0x6A, 0xBF, 0x0D, 0xF4,
+	0xB3,
+}
+
+var duplicateLetters = []byte("BEFAARBEKEK R-URAR INAILICE R")
+
+// index into compatCombinations for each entry in titleChecksums
+var checksumCombinations = []byte{
+	0, 4, 5, 35, 34, 3, 31, 15, 10, 5, 19, 36, 7, 37, 30, 44,
+	21, 32, 31, 20, 5, 33, 13, 14, 5, 29, 5, 18, 9, 3, 2, 26,
+	25, 25, 41, 42, 26, 45, 42, 45, 36, 38, 26, 42, 30, 41, 34, 34,
+	5, 42, 6, 5, 33, 25, 42, 42, 40, 2, 16, 25, 42, 42, 5, 0,
+	39,
+	36, 22, 25, 6, 32, 12, 36, 11, 39, 18, 39, 24, 31, 50,
+	17, 46, 6, 27, 0, 0, 47, 0, 0, 0, 0, 0, 0, 0,
+	0,
+}
+
+// combinations picked by holding a direction (and optionally A or B) while the CGB logo is shown
+var manualCombinations = map[string]byte{
+	"right":   1,
+	"left":    48,
+	"up":      5,
+	"down":    8,
+	"right+a": 0,
+	"left+a":  40,
+	"up+a":    43,
+	"down+a":  3,
+	"right+b": 6,
+	"left+b":  7,
+	"up+b":    28,
+	"down+b":  49,
+}
+
+// GetCompatPalette picks the palette the CGB boot ROM would use for a DMG game.
+// title is the full 16 byte title area of the header (0x134-0x143).
+func GetCompatPalette(title []byte, isNintendo bool) *CompatPalette {
+	if !isNintendo || len(title) < TITLE_CHECKSUM_LENGTH {
+		return newCompatPalette(0)
+	}
+
+	var checksum byte = 0
+	for _, val := range title[:TITLE_CHECKSUM_LENGTH] {
+		checksum += val
+	}
+
+	for idx, val := range titleChecksums {
+		if val != checksum {
+			continue
+		}
+		if idx >= FIRST_DUPLICATE_IDX && title[3] != duplicateLetters[idx-FIRST_DUPLICATE_IDX] {
+			continue
+		}
+		return newCompatPalette(checksumCombinations[idx])
+	}
+
+	return newCompatPalette(0)
+}
+
+// GetManualCompatPalette returns one of the 12 button combinations, e.g. "up", "left+a" or "down+b"
+func GetManualCompatPalette(combo string) (*CompatPalette, error) {
+	idx, ok := manualCombinations[strings.ToLower(combo)]
+	if !ok {
+		return nil, fmt.Errorf("unknown palette combination: %s", combo)
+	}
+
+	return newCompatPalette(idx), nil
+}
+
+func newCompatPalette(combination byte) *CompatPalette {
+	offsets := compatCombinations[combination]
+	return &CompatPalette{
+		obj0: getCompatColours(offsets[0]),
+		obj1: getCompatColours(offsets[1]),
+		bg:   getCompatColours(offsets[2]),
+	}
+}
+
+func getCompatColours(offset byte) [4]uint32 {
+	var colours [4]uint32
+	for i := range colours {
+		colours[i] = colour.Bgr15ToRGBA(compatColours[int(offset)+i])
+	}
+	return colours
+}
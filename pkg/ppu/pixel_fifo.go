@@ -9,6 +9,10 @@ type Pixel struct {
 	colourNum   byte
 	paletteAddr uint16
 	priority    byte
+
+	// CGB only
+	cgbPalette byte
+	oamIdx     byte
 }
 
 func newPixel(colourNum byte, paletteAddr uint16, priority byte) *Pixel {
@@ -4,6 +4,7 @@ import (
 	"cmp"
 	"github.com/siliconandsolder/go-boy/pkg/bus"
 	"github.com/siliconandsolder/go-boy/pkg/interrupts"
+	"github.com/siliconandsolder/go-boy/pkg/sgb"
 	"slices"
 )
 
@@ -53,6 +54,10 @@ type Ppu struct {
 	fgFetcher   *SpriteFetcher
 	shouldCycle bool
 	bus         *bus.Bus
+
+	// colours used for DMG games instead of greyscale, nil if disabled
+	compatPalette *CompatPalette
+	sgb           *sgb.Sgb
 }
 
 func NewPPU(bus *bus.Bus) *Ppu {
@@ -79,9 +84,22 @@ func NewPPU(bus *bus.Bus) *Ppu {
 		bgFetcher:   newBgFetcher(bus, lcdc, scs),
 		fgFetcher:   newSpriteFetcher(bus, lcdc),
 		bus:         bus,
+
+		compatPalette: nil,
+		sgb:           nil,
 	}
 }
 
+// SetSgb colours the screen with the Super Game Boy's palettes, and lets it read VRAM for transfers
+func (ppu *Ppu) SetSgb(s *sgb.Sgb) {
+	ppu.sgb = s
+}
+
+// SetCompatPalette colourizes DMG games the way the CGB does. Passing nil restores greyscale.
+func (ppu *Ppu) SetCompatPalette(palette *CompatPalette) {
+	ppu.compatPalette = palette
+}
+
 func (ppu *Ppu) Cycle(cycles byte) ([]uint32, error) {
 
 	ppu.readRegisters()
@@ -150,32 +168,12 @@ func (ppu *Ppu) Cycle(cycles byte) ([]uint32, error) {
 				if !ppu.fgFetcher.fifo.isEmpty() {
 					bgPixel := ppu.bgFetcher.fifo.pop()
 					fgPixel := ppu.fgFetcher.fifo.pop()
-
-					if ppu.lcdControl.bgWindowEnabled == 0 {
-						bgPixel.colourNum = 0
-					}
-					if ppu.lcdControl.objEnabled == 0 {
-						fgPixel.colourNum = 0
-					}
-
-					if fgPixel.colourNum == 0 || (fgPixel.priority == 1 && bgPixel.colourNum != 0) {
-						colour := (ppu.bus.Read(bgPixel.paletteAddr) & (0x3 << (bgPixel.colourNum * 2))) >> (bgPixel.colourNum * 2)
-						ppu.pixelBuffer[ppu.pixelIdx] = getColour(colour)
-						ppu.pixelIdx++
-					} else {
-						colour := (ppu.bus.Read(fgPixel.paletteAddr) & (0x3 << (fgPixel.colourNum * 2))) >> (fgPixel.colourNum * 2)
-						ppu.pixelBuffer[ppu.pixelIdx] = getColour(colour)
-						ppu.pixelIdx++
-					}
+					ppu.pixelBuffer[ppu.pixelIdx] = ppu.mixPixels(bgPixel, fgPixel)
 				} else {
 					pixel := ppu.bgFetcher.fifo.pop()
-					if ppu.lcdControl.bgWindowEnabled == 0 {
-						pixel.colourNum = 0
-					}
-					colour := (ppu.bus.Read(pixel.paletteAddr) & (0x3 << (pixel.colourNum * 2))) >> (pixel.colourNum * 2)
-					ppu.pixelBuffer[ppu.pixelIdx] = getColour(colour)
-					ppu.pixelIdx++
+					ppu.pixelBuffer[ppu.pixelIdx] = ppu.mixPixels(pixel, nil)
 				}
+				ppu.pixelIdx++
 
 				ppu.x++
 				ppu.bgFetcher.resetIfWindow(ppu.x, ppu.ly)
@@ -186,6 +184,7 @@ func (ppu *Ppu) Cycle(cycles byte) ([]uint32, error) {
 				ppu.x = 0
 				ppu.bus.SetVramAccessible(true)
 				ppu.bus.SetOamAccessible(true)
+				ppu.bus.SignalHBlank()
 				if ppu.lcdStatus.hBlankStatInterrupt == 1 {
 					ppu.bus.ToggleInterrupt(interrupts.LCDSTAT)
 				}
@@ -215,6 +214,10 @@ func (ppu *Ppu) Cycle(cycles byte) ([]uint32, error) {
 				ppu.loadOams()
 				ppu.pixelIdx = 0
 				ppu.bufferReady = true
+
+				if ppu.sgb != nil && ppu.sgb.IsTransferPending() {
+					ppu.sgb.Transfer(ppu.readScreenTiles())
+				}
 			}
 			break
 		}
@@ -315,6 +318,9 @@ func (ppu *Ppu) loadOams() {
 				yFlip:    oamAttrs >> 6 & 1,
 				xFlip:    oamAttrs >> 5 & 1,
 				palette:  oamAttrs >> 4 & 1,
+
+				vramBank:   oamAttrs >> 3 & 1,
+				cgbPalette: oamAttrs & 7,
 			},
 		}
 	}
@@ -330,3 +336,101 @@ func (ppu *Ppu) checkForSprite() *OamObj {
 
 	return nil
 }
+
+// mixPixels picks the background or sprite pixel and returns its final colour. fgPixel may be nil.
+func (ppu *Ppu) mixPixels(bgPixel *Pixel, fgPixel *Pixel) uint32 {
+	// on the CGB, LCDC bit 0 removes background priority instead of blanking the background
+	if ppu.lcdControl.bgWindowEnabled == 0 && !ppu.bus.IsCGB() {
+		bgPixel.colourNum = 0
+	}
+	if fgPixel != nil && ppu.lcdControl.objEnabled == 0 {
+		fgPixel.colourNum = 0
+	}
+
+	if fgPixel == nil || fgPixel.colourNum == 0 || ppu.bgHasPriority(bgPixel, fgPixel) {
+		return ppu.getBgColour(bgPixel)
+	}
+	return ppu.getObjColour(fgPixel)
+}
+
+func (ppu *Ppu) bgHasPriority(bgPixel *Pixel, fgPixel *Pixel) bool {
+	if bgPixel.colourNum == 0 {
+		return false
+	}
+
+	if ppu.bus.IsCGB() {
+		if ppu.lcdControl.bgWindowEnabled == 0 {
+			return false
+		}
+		return bgPixel.priority == 1 || fgPixel.priority == 1
+	}
+
+	return fgPixel.priority == 1
+}
+
+func (ppu *Ppu) getBgColour(pixel *Pixel) uint32 {
+	if ppu.bus.IsCGB() {
+		idx := pixel.cgbPalette*8 + pixel.colourNum*2
+		return getCgbColour(ppu.bus.PpuReadBgPalette(idx), ppu.bus.PpuReadBgPalette(idx+1))
+	}
+	if ppu.sgb != nil {
+		return ppu.getSgbColour(pixel)
+	}
+	if ppu.compatPalette != nil {
+		return ppu.compatPalette.bg[ppu.getDmgShade(pixel)]
+	}
+	return getColour(ppu.getDmgShade(pixel))
+}
+
+func (ppu *Ppu) getObjColour(pixel *Pixel) uint32 {
+	if ppu.bus.IsCGB() {
+		idx := pixel.cgbPalette*8 + pixel.colourNum*2
+		return getCgbColour(ppu.bus.PpuReadObjPalette(idx), ppu.bus.PpuReadObjPalette(idx+1))
+	}
+	if ppu.sgb != nil {
+		return ppu.getSgbColour(pixel)
+	}
+	if ppu.compatPalette != nil {
+		if pixel.paletteAddr == SPRITE_DATA_ZERO {
+			return ppu.compatPalette.obj0[ppu.getDmgShade(pixel)]
+		}
+		return ppu.compatPalette.obj1[ppu.getDmgShade(pixel)]
+	}
+	return getColour(ppu.getDmgShade(pixel))
+}
+
+func (ppu *Ppu) getDmgShade(pixel *Pixel) byte {
+	return (ppu.bus.Read(pixel.paletteAddr) & (0x3 << (pixel.colourNum * 2))) >> (pixel.colourNum * 2)
+}
+
+func (ppu *Ppu) getSgbColour(pixel *Pixel) uint32 {
+	x := byte(ppu.pixelIdx % MAX_PIXEL_TRANSFER)
+	y := byte(ppu.pixelIdx / MAX_PIXEL_TRANSFER)
+	return ppu.sgb.GetColour(x, y, ppu.getDmgShade(pixel))
+}
+
+// readScreenTiles collects the tile data of the first 256 background tiles on screen, in the order
+// they are displayed. The SGB reads its transfers this way, rather than straight from VRAM.
+func (ppu *Ppu) readScreenTiles() []byte {
+	data := make([]byte, 0, sgb.TRANSFER_SIZE)
+
+	mapBase := uint16(TILE_MAP_START_ZERO)
+	if ppu.lcdControl.bgTileMapArea == 1 {
+		mapBase = TILE_MAP_START_ONE
+	}
+
+	for i := uint16(0); i < 256; i++ {
+		tileId := ppu.bus.PpuReadVram(mapBase + (i/20)*32 + i%20)
+
+		tileAddr := uint16(TILE_DATA_START_ZERO) + uint16(tileId)*16
+		if ppu.lcdControl.tileDataArea == 0 && tileId < 128 {
+			tileAddr = TILE_DATA_START_TWO + uint16(tileId)*16
+		}
+
+		for b := uint16(0); b < 16; b++ {
+			data = append(data, ppu.bus.PpuReadVram(tileAddr+b))
+		}
+	}
+
+	return data
+}
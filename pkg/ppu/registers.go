@@ -72,6 +72,10 @@ type OamAttributes struct {
 	yFlip    byte
 	xFlip    byte
 	palette  byte
+
+	// CGB only
+	vramBank   byte
+	cgbPalette byte
 }
 
 type OamObj struct {
@@ -93,6 +97,9 @@ func NewOamObj() *OamObj {
 			yFlip:    0,
 			xFlip:    0,
 			palette:  0,
+
+			vramBank:   0,
+			cgbPalette: 0,
 		},
 	}
 }
@@ -80,7 +80,10 @@ func (s *SpriteFetcher) cycle(shouldCycle bool) {
 				paletteAddr = SPRITE_DATA_ONE
 			}
 
-			if err := tempFifo.push(newPixel(colourNum, paletteAddr, priority)); err != nil {
+			pixel := newPixel(colourNum, paletteAddr, priority)
+			pixel.cgbPalette = s.spriteToFetch.attributes.cgbPalette
+			pixel.oamIdx = s.spriteToFetch.idx
+			if err := tempFifo.push(pixel); err != nil {
 				panic(err)
 			}
 		}
@@ -113,7 +116,11 @@ func (s *SpriteFetcher) readTileData(isHigh bool) {
 	if s.spriteToFetch.attributes.yFlip == 1 {
 		offset = (spriteHeight-1)*2 - offset
 	}
-	data := s.bus.PpuReadVram(tileAddr + offset)
+	var bank byte = 0
+	if s.bus.IsCGB() {
+		bank = s.spriteToFetch.attributes.vramBank
+	}
+	data := s.bus.PpuReadVramBank(tileAddr+offset, bank)
 
 	if isHigh {
 		s.dataHigh = data
@@ -131,10 +138,18 @@ func (s *SpriteFetcher) mixFifos(tempFifo *PixelFIFO) {
 			}
 			continue
 		}
-		if tempFifo.queue[i].colourNum != 0 && s.fifo.queue[i].colourNum == 0 {
+		// the CGB gives priority to the sprite that comes first in OAM, rather than the leftmost sprite
+		replace := s.fifo.queue[i].colourNum == 0
+		if s.bus.IsCGB() && tempFifo.queue[i].oamIdx < s.fifo.queue[i].oamIdx {
+			replace = true
+		}
+
+		if tempFifo.queue[i].colourNum != 0 && replace {
 			s.fifo.queue[i].colourNum = tempFifo.queue[i].colourNum
 			s.fifo.queue[i].priority = tempFifo.queue[i].priority
 			s.fifo.queue[i].paletteAddr = tempFifo.queue[i].paletteAddr
+			s.fifo.queue[i].cgbPalette = tempFifo.queue[i].cgbPalette
+			s.fifo.queue[i].oamIdx = tempFifo.queue[i].oamIdx
 		}
 	}
 }
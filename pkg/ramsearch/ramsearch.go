@@ -0,0 +1,272 @@
+package ramsearch
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	REGION_WRAM = "wram"
+	REGION_HRAM = "hram"
+	REGION_SRAM = "sram"
+
+	WRAM_START      = 0xC000
+	WRAM_BANK_START = 0xD000
+	WRAM_BANK_SIZE  = 0x1000
+	CGB_WRAM_BANKS  = 8
+	HRAM_START      = 0xFF80
+	HRAM_SIZE       = 0x7F
+	SRAM_START      = 0xA000
+	SRAM_BANK_SIZE  = 0x2000
+)
+
+// Memory is what a search reads from. Banked reads let a search cover all of work and cartridge RAM,
+// not just the banks the game has mapped in.
+type Memory interface {
+	ReadBank(bank byte, addr uint16) byte
+	IsCGB() bool
+	GetCartRamSize() int
+}
+
+type Comparison int
+
+const (
+	EQUAL Comparison = iota
+	NOT_EQUAL
+	GREATER
+	LESS
+)
+
+// Filter compares each candidate against its value at the last snapshot, or against Value if HasValue is set
+type Filter struct {
+	Comparison Comparison
+	Value      uint16
+	HasValue   bool
+}
+
+// ParseFilter reads a filter such as "changed", "gt", or "eq 100". Values may be decimal or 0x-prefixed hex.
+func ParseFilter(args []string) (Filter, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return Filter{}, fmt.Errorf("expected a comparison and an optional value")
+	}
+
+	var filter Filter
+	name := strings.ToLower(args[0])
+	switch name {
+	case "eq", "equal", "unchanged":
+		filter.Comparison = EQUAL
+	case "ne", "changed":
+		filter.Comparison = NOT_EQUAL
+	case "gt", "greater":
+		filter.Comparison = GREATER
+	case "lt", "less":
+		filter.Comparison = LESS
+	default:
+		return Filter{}, fmt.Errorf("unknown comparison %q: expected eq, ne, gt, lt, changed or unchanged", args[0])
+	}
+
+	if len(args) == 2 {
+		if name == "changed" || name == "unchanged" {
+			return Filter{}, fmt.Errorf("%s compares against the last snapshot and takes no value", name)
+		}
+		value, err := strconv.ParseUint(args[1], 0, 16)
+		if err != nil {
+			return Filter{}, fmt.Errorf("invalid value %q: %w", args[1], err)
+		}
+		filter.Value = uint16(value)
+		filter.HasValue = true
+	}
+
+	return filter, nil
+}
+
+func (f Filter) matches(current uint16, previous uint16) bool {
+	other := previous
+	if f.HasValue {
+		other = f.Value
+	}
+
+	switch f.Comparison {
+	case EQUAL:
+		return current == other
+	case NOT_EQUAL:
+		return current != other
+	case GREATER:
+		return current > other
+	case LESS:
+		return current < other
+	}
+	return false
+}
+
+type location struct {
+	region string
+	bank   byte
+	addr   uint16
+}
+
+// Candidate is an address that has matched every filter so far. Previous is its value at the last snapshot.
+type Candidate struct {
+	Region   string `json:"region"`
+	Bank     byte   `json:"bank"`
+	Address  string `json:"address"`
+	Value    uint16 `json:"value"`
+	Previous uint16 `json:"previous"`
+}
+
+// Search narrows down where a game keeps a value by comparing snapshots of RAM taken across frames
+type Search struct {
+	// 1 or 2 bytes per value
+	Size      int
+	BigEndian bool
+
+	locations  []location
+	snapshot   []byte
+	candidates []int // indices into locations
+}
+
+func NewSearch() *Search {
+	return &Search{
+		Size:       1,
+		BigEndian:  false,
+		locations:  nil,
+		snapshot:   nil,
+		candidates: nil,
+	}
+}
+
+// SetSize sets the width of searched values, in bits
+func (s *Search) SetSize(bits int) error {
+	if bits != 8 && bits != 16 {
+		return fmt.Errorf("values are 8 or 16 bits wide, not %d", bits)
+	}
+	s.Size = bits / 8
+	return nil
+}
+
+// Reset snapshots WRAM, HRAM and cartridge RAM and makes every address a candidate again
+func (s *Search) Reset(mem Memory) {
+	s.locations = getLocations(mem)
+	s.snapshot = s.read(mem)
+	s.candidates = make([]int, len(s.locations))
+	for i := range s.candidates {
+		s.candidates[i] = i
+	}
+}
+
+// Filter drops candidates that don't match, then takes a new snapshot to compare the next filter against
+func (s *Search) Filter(mem Memory, filter Filter) error {
+	if s.snapshot == nil {
+		return fmt.Errorf("no snapshot yet")
+	}
+
+	current := s.read(mem)
+	kept := s.candidates[:0]
+	for _, idx := range s.candidates {
+		value, ok := s.getValue(current, idx)
+		if !ok {
+			continue
+		}
+		previous, _ := s.getValue(s.snapshot, idx)
+		if filter.matches(value, previous) {
+			kept = append(kept, idx)
+		}
+	}
+
+	s.candidates = kept
+	s.snapshot = current
+	return nil
+}
+
+// Count returns how many candidates are left
+func (s *Search) Count() int {
+	return len(s.candidates)
+}
+
+// GetCandidates returns up to limit candidates with their current values, or all of them if limit is 0
+func (s *Search) GetCandidates(mem Memory, limit int) []Candidate {
+	if s.snapshot == nil {
+		return []Candidate{}
+	}
+
+	current := s.read(mem)
+	candidates := make([]Candidate, 0)
+	for _, idx := range s.candidates {
+		if limit > 0 && len(candidates) == limit {
+			break
+		}
+		value, ok := s.getValue(current, idx)
+		if !ok {
+			continue
+		}
+		previous, _ := s.getValue(s.snapshot, idx)
+		loc := s.locations[idx]
+		candidates = append(candidates, Candidate{
+			Region:   loc.region,
+			Bank:     loc.bank,
+			Address:  fmt.Sprintf("%04X", loc.addr),
+			Value:    value,
+			Previous: previous,
+		})
+	}
+	return candidates
+}
+
+// getValue reads the value starting at idx. 16-bit values can't straddle two regions or banks.
+func (s *Search) getValue(data []byte, idx int) (uint16, bool) {
+	if s.Size == 1 {
+		return uint16(data[idx]), true
+	}
+
+	if idx+1 >= len(s.locations) {
+		return 0, false
+	}
+	loc, next := s.locations[idx], s.locations[idx+1]
+	if loc.region != next.region || loc.bank != next.bank || loc.addr+1 != next.addr {
+		return 0, false
+	}
+
+	if s.BigEndian {
+		return uint16(data[idx])<<8 | uint16(data[idx+1]), true
+	}
+	return uint16(data[idx+1])<<8 | uint16(data[idx]), true
+}
+
+func (s *Search) read(mem Memory) []byte {
+	data := make([]byte, len(s.locations))
+	for i, loc := range s.locations {
+		data[i] = mem.ReadBank(loc.bank, loc.addr)
+	}
+	return data
+}
+
+// getLocations lists every searchable byte: fixed work RAM, each switchable bank (only bank 1 outside CGB mode),
+// high RAM, then each bank of cartridge RAM
+func getLocations(mem Memory) []location {
+	locations := make([]location, 0)
+
+	for addr := 0; addr < WRAM_BANK_SIZE; addr++ {
+		locations = append(locations, location{REGION_WRAM, 0, uint16(WRAM_START + addr)})
+	}
+
+	lastBank := 1
+	if mem.IsCGB() {
+		lastBank = CGB_WRAM_BANKS - 1
+	}
+	for bank := 1; bank <= lastBank; bank++ {
+		for addr := 0; addr < WRAM_BANK_SIZE; addr++ {
+			locations = append(locations, location{REGION_WRAM, byte(bank), uint16(WRAM_BANK_START + addr)})
+		}
+	}
+
+	for addr := 0; addr < HRAM_SIZE; addr++ {
+		locations = append(locations, location{REGION_HRAM, 0, uint16(HRAM_START + addr)})
+	}
+
+	for idx := 0; idx < mem.GetCartRamSize(); idx++ {
+		locations = append(locations, location{REGION_SRAM, byte(idx / SRAM_BANK_SIZE), uint16(SRAM_START + idx%SRAM_BANK_SIZE)})
+	}
+
+	return locations
+}
@@ -0,0 +1,148 @@
+package romfile
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const TAR_MAGIC_OFFSET = 257
+
+var zipMagic = []byte("PK\x03\x04")
+var gzipMagic = []byte{0x1F, 0x8B}
+var tarMagic = []byte("ustar")
+
+var romExtensions = []string{".gb", ".gbc", ".cgb", ".sgb"}
+
+// Load reads a ROM, unpacking it first if it is in a zip, gzip or tar.gz archive. The returned name is the ROM's own
+// file name, not the archive's. entry picks a ROM inside a zip or tar archive by name; if it is empty, the only ROM
+// in the archive is used, or the first when there are several.
+func Load(fileName string, entry string) ([]byte, string, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, "", err
+	}
+
+	name := filepath.Base(fileName)
+	if bytes.HasPrefix(data, zipMagic) {
+		return loadZip(data, entry)
+	} else if bytes.HasPrefix(data, gzipMagic) {
+		return loadGzip(data, name, entry)
+	}
+
+	return data, name, nil
+}
+
+func loadZip(data []byte, entry string) ([]byte, string, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, "", err
+	}
+
+	var names []string
+	for _, file := range reader.File {
+		if !file.FileInfo().IsDir() {
+			names = append(names, file.Name)
+		}
+	}
+
+	romName, err := pickEntry(names, entry)
+	if err != nil {
+		return nil, "", err
+	}
+
+	file, err := reader.Open(romName)
+	if err != nil {
+		return nil, "", err
+	}
+	defer file.Close()
+
+	rom, err := io.ReadAll(file)
+	if err != nil {
+		return nil, "", err
+	}
+	return rom, path.Base(romName), nil
+}
+
+func loadGzip(data []byte, archiveName string, entry string) ([]byte, string, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	defer reader.Close()
+
+	unpacked, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(unpacked) >= TAR_MAGIC_OFFSET+len(tarMagic) && bytes.Equal(unpacked[TAR_MAGIC_OFFSET:TAR_MAGIC_OFFSET+len(tarMagic)], tarMagic) {
+		return loadTar(unpacked, entry)
+	}
+
+	// a plain .gz holds a single file, and may remember its name
+	name := reader.Name
+	if name == "" {
+		name = strings.TrimSuffix(archiveName, filepath.Ext(archiveName))
+	}
+	return unpacked, filepath.Base(name), nil
+}
+
+func loadTar(data []byte, entry string) ([]byte, string, error) {
+	files := make(map[string][]byte)
+	var names []string
+
+	reader := tar.NewReader(bytes.NewReader(data))
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, "", err
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		contents, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, "", err
+		}
+		files[header.Name] = contents
+		names = append(names, header.Name)
+	}
+
+	romName, err := pickEntry(names, entry)
+	if err != nil {
+		return nil, "", err
+	}
+	return files[romName], path.Base(romName), nil
+}
+
+// pickEntry matches entry against the full path or just the file name of each archive member
+func pickEntry(names []string, entry string) (string, error) {
+	if entry != "" {
+		for _, name := range names {
+			if name == entry || path.Base(name) == entry {
+				return name, nil
+			}
+		}
+		return "", fmt.Errorf("%s not found in archive", entry)
+	}
+
+	for _, name := range names {
+		if slices.Contains(romExtensions, strings.ToLower(path.Ext(name))) {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("no .gb or .gbc file found in archive")
+}
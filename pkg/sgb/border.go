@@ -0,0 +1,86 @@
+package sgb
+
+import "github.com/siliconandsolder/go-boy/pkg/colour"
+
+const (
+	BORDER_TILES      = 256
+	BORDER_TILE_SIZE  = 32 // SNES 4bpp
+	BORDER_MAP_WIDTH  = 32
+	BORDER_MAP_HEIGHT = 28
+	BORDER_PALETTES   = 4
+	BORDER_COLOURS    = 16
+
+	PALETTE_DATA_OFFSET = 0x800
+)
+
+type border struct {
+	tiles    []byte
+	tileMap  []uint16
+	palettes [BORDER_PALETTES][BORDER_COLOURS]uint32
+}
+
+func newBorder() *border {
+	return &border{
+		tiles:   make([]byte, BORDER_TILES*BORDER_TILE_SIZE),
+		tileMap: make([]uint16, BORDER_MAP_WIDTH*BORDER_MAP_HEIGHT),
+	}
+}
+
+// setTiles receives 128 tiles from CHR_TRN, bank 1 holding tiles 0x80-0xFF
+func (b *border) setTiles(bank byte, data []byte) {
+	copy(b.tiles[int(bank)*TRANSFER_SIZE:], data)
+}
+
+// setMap receives the 32x32 tile map from PCT_TRN, followed by border palettes 4-7
+func (b *border) setMap(data []byte) {
+	for i := range b.tileMap {
+		b.tileMap[i] = uint16(data[i*2+1])<<8 | uint16(data[i*2])
+	}
+
+	for p := 0; p < BORDER_PALETTES; p++ {
+		for c := 0; c < BORDER_COLOURS; c++ {
+			offset := PALETTE_DATA_OFFSET + (p*BORDER_COLOURS+c)*2
+			b.palettes[p][c] = colour.Bgr15ToRGBA(uint16(data[offset+1])<<8 | uint16(data[offset]))
+		}
+	}
+}
+
+// render draws the border into a 256x224 frame. Colour 0 of each tile is transparent and shows the backdrop.
+func (b *border) render(frame []uint32, backdrop uint32) {
+	for mapY := 0; mapY < BORDER_MAP_HEIGHT; mapY++ {
+		for mapX := 0; mapX < BORDER_MAP_WIDTH; mapX++ {
+			entry := b.tileMap[mapY*BORDER_MAP_WIDTH+mapX]
+			tile := b.tiles[int(entry&0xFF)*BORDER_TILE_SIZE:]
+			palette := (entry >> 10 & 7) % BORDER_PALETTES
+			xFlip := entry&0x4000 == 0x4000
+			yFlip := entry&0x8000 == 0x8000
+
+			for row := 0; row < 8; row++ {
+				tileRow := row
+				if yFlip {
+					tileRow = 7 - row
+				}
+
+				plane0 := tile[tileRow*2]
+				plane1 := tile[tileRow*2+1]
+				plane2 := tile[16+tileRow*2]
+				plane3 := tile[16+tileRow*2+1]
+
+				for col := 0; col < 8; col++ {
+					bit := 7 - col
+					if xFlip {
+						bit = col
+					}
+
+					colourNum := (plane0>>bit)&1 | ((plane1>>bit)&1)<<1 | ((plane2>>bit)&1)<<2 | ((plane3>>bit)&1)<<3
+
+					colour := backdrop
+					if colourNum != 0 {
+						colour = b.palettes[palette][colourNum]
+					}
+					frame[(mapY*8+row)*FRAME_WIDTH+mapX*8+col] = colour
+				}
+			}
+		}
+	}
+}
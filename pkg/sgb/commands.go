@@ -0,0 +1,173 @@
+package sgb
+
+import "github.com/siliconandsolder/go-boy/pkg/colour"
+
+const (
+	PAL01    = 0x00
+	PAL23    = 0x01
+	PAL03    = 0x02
+	PAL12    = 0x03
+	ATTR_BLK = 0x04
+	ATTR_LIN = 0x05
+	ATTR_CHR = 0x07
+	MLT_REQ  = 0x11
+	CHR_TRN  = 0x13
+	PCT_TRN  = 0x14
+	MASK_EN  = 0x17
+)
+
+const (
+	MASK_CANCEL = iota
+	MASK_FREEZE
+	MASK_BLACK
+	MASK_COLOUR_0
+)
+
+func (s *Sgb) runCommand(command byte, data []byte) {
+	switch command {
+	case PAL01:
+		s.setPalettes(0, 1, data)
+	case PAL23:
+		s.setPalettes(2, 3, data)
+	case PAL03:
+		s.setPalettes(0, 3, data)
+	case PAL12:
+		s.setPalettes(1, 2, data)
+	case ATTR_BLK:
+		s.attrBlock(data)
+	case ATTR_LIN:
+		s.attrLine(data)
+	case ATTR_CHR:
+		s.attrChr(data)
+	case MLT_REQ:
+		switch data[1] & 3 {
+		case 1:
+			s.numPlayers = 2
+		case 3:
+			s.numPlayers = 4
+		default:
+			s.numPlayers = 1
+		}
+		s.player = 0
+	case CHR_TRN:
+		s.pending = chrTransfer
+		s.chrBank = data[1] & 1
+	case PCT_TRN:
+		s.pending = pctTransfer
+	case MASK_EN:
+		s.mask = data[1] & 3
+	}
+}
+
+// setPalettes sets colours 1-3 of two palettes. Colour 0 is shared by every palette.
+func (s *Sgb) setPalettes(first byte, second byte, data []byte) {
+	colour0 := colour.Bgr15ToRGBA(uint16(data[2])<<8 | uint16(data[1]))
+	for i := range s.palettes {
+		s.palettes[i][0] = colour0
+	}
+
+	for i := 0; i < 3; i++ {
+		s.palettes[first][i+1] = colour.Bgr15ToRGBA(uint16(data[4+i*2])<<8 | uint16(data[3+i*2]))
+		s.palettes[second][i+1] = colour.Bgr15ToRGBA(uint16(data[10+i*2])<<8 | uint16(data[9+i*2]))
+	}
+}
+
+// attrBlock colours the inside, outside and border of up to 18 rectangles
+func (s *Sgb) attrBlock(data []byte) {
+	numSets := int(data[1] & 0x1F)
+	for set := 0; set < numSets; set++ {
+		offset := 2 + set*6
+		if offset+6 > len(data) {
+			break
+		}
+
+		control := data[offset] & 7
+		inside := data[offset+1] & 3
+		border := data[offset+1] >> 2 & 3
+		outside := data[offset+1] >> 4 & 3
+		x1, y1 := data[offset+2]&0x1F, data[offset+3]&0x1F
+		x2, y2 := data[offset+4]&0x1F, data[offset+5]&0x1F
+
+		// if only one of inside or outside is set, the border uses the same palette
+		if control == 1 {
+			control |= 2
+			border = inside
+		} else if control == 4 {
+			control |= 2
+			border = outside
+		}
+
+		for y := byte(0); y < 18; y++ {
+			for x := byte(0); x < 20; x++ {
+				idx := int(y)*20 + int(x)
+				if x > x1 && x < x2 && y > y1 && y < y2 {
+					if control&1 == 1 {
+						s.attrMap[idx] = inside
+					}
+				} else if x >= x1 && x <= x2 && y >= y1 && y <= y2 {
+					if control&2 == 2 {
+						s.attrMap[idx] = border
+					}
+				} else if control&4 == 4 {
+					s.attrMap[idx] = outside
+				}
+			}
+		}
+	}
+}
+
+// attrLine colours whole rows or columns
+func (s *Sgb) attrLine(data []byte) {
+	numLines := int(data[1])
+	for i := 0; i < numLines && 2+i < len(data); i++ {
+		line := data[2+i] & 0x1F
+		palette := data[2+i] >> 5 & 3
+
+		if data[2+i]&0x80 == 0x80 { // horizontal
+			if line >= 18 {
+				continue
+			}
+			for x := 0; x < 20; x++ {
+				s.attrMap[int(line)*20+x] = palette
+			}
+		} else {
+			if line >= 20 {
+				continue
+			}
+			for y := 0; y < 18; y++ {
+				s.attrMap[y*20+int(line)] = palette
+			}
+		}
+	}
+}
+
+// attrChr colours individual cells, four per byte, starting at a position and moving across or down
+func (s *Sgb) attrChr(data []byte) {
+	x := int(data[1] % 20)
+	y := int(data[2] % 18)
+	numCells := int(data[4])<<8 | int(data[3])
+	vertical := data[5]&1 == 1
+
+	for i := 0; i < numCells && i < SCREEN_TILES; i++ {
+		offset := 6 + i/4
+		if offset >= len(data) {
+			break
+		}
+
+		s.attrMap[y*20+x] = data[offset] >> (6 - (i%4)*2) & 3
+
+		if vertical {
+			y++
+			if y == 18 {
+				y = 0
+				x = (x + 1) % 20
+			}
+		} else {
+			x++
+			if x == 20 {
+				x = 0
+				y = (y + 1) % 18
+			}
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package sgb
+
+const (
+	PACKET_SIZE  = 16
+	PACKET_BITS  = PACKET_SIZE * 8
+	MAX_PACKETS  = 7
+	SCREEN_WIDTH = 160
+	SCREEN_TILES = 20 * 18
+
+	FRAME_WIDTH  = 256
+	FRAME_HEIGHT = 224
+	SCREEN_X     = 48
+	SCREEN_Y     = 40
+
+	TRANSFER_SIZE = 0x1000
+)
+
+type transferType byte
+
+const (
+	noTransfer transferType = iota
+	chrTransfer
+	pctTransfer
+)
+
+// Sgb listens to writes to the joypad register for command packets, and colours the DMG screen
+// using its palettes and attribute map
+type Sgb struct {
+	// packet transfer
+	lastJoypad    byte
+	receiving     bool
+	waitForHigh   bool
+	bitIdx        int
+	packet        []byte
+	packets       []byte
+	packetsNeeded byte
+
+	// multiplayer
+	numPlayers byte
+	player     byte
+
+	palettes [4][4]uint32
+	attrMap  []byte
+	mask     byte
+	frozen   []uint32
+	frame    []uint32
+	pending  transferType
+	chrBank  byte
+	border   *border
+}
+
+func NewSgb() *Sgb {
+	s := &Sgb{
+		lastJoypad:    0x30,
+		receiving:     false,
+		waitForHigh:   false,
+		bitIdx:        0,
+		packet:        make([]byte, PACKET_SIZE),
+		packets:       make([]byte, 0, PACKET_SIZE*MAX_PACKETS),
+		packetsNeeded: 0,
+		numPlayers:    1,
+		player:        0,
+		attrMap:       make([]byte, SCREEN_TILES),
+		mask:          MASK_CANCEL,
+		frozen:        make([]uint32, SCREEN_WIDTH*144),
+		frame:         make([]uint32, FRAME_WIDTH*FRAME_HEIGHT),
+		pending:       noTransfer,
+		chrBank:       0,
+		border:        newBorder(),
+	}
+
+	// same shades as the DMG until the game sends its own palettes
+	for i := range s.palettes {
+		s.palettes[i] = [4]uint32{0xFFFFFFFF, 0xD3D3D3FF, 0x808080FF, 0x000000FF}
+	}
+
+	return s
+}
+
+// WriteJoypad decodes packets sent by pulsing P14 and P15. Both low resets the transfer, P14 low sends a 0,
+// P15 low sends a 1, and both lines must go high again between bits.
+func (s *Sgb) WriteJoypad(val byte) {
+	lines := val & 0x30
+
+	// the next controller is selected when P15 goes high
+	if s.numPlayers > 1 && s.lastJoypad&0x20 == 0 && lines&0x20 == 0x20 {
+		s.player = (s.player + 1) % s.numPlayers
+	}
+	s.lastJoypad = lines
+
+	switch lines {
+	case 0x00:
+		s.receiving = true
+		s.waitForHigh = true
+		s.bitIdx = 0
+		clear(s.packet)
+		return
+	case 0x30:
+		s.waitForHigh = false
+		return
+	}
+
+	if !s.receiving || s.waitForHigh {
+		return
+	}
+	s.waitForHigh = true
+
+	var bit byte = 0
+	if lines == 0x10 {
+		bit = 1
+	}
+
+	if s.bitIdx == PACKET_BITS {
+		// stop bit, which must be 0
+		s.receiving = false
+		if bit == 0 {
+			s.receivePacket()
+		}
+		return
+	}
+
+	s.packet[s.bitIdx/8] |= bit << (s.bitIdx % 8)
+	s.bitIdx++
+}
+
+// ReadJoypad adjusts the controller's value for multiplayer. With neither line selected the current player's ID
+// is returned, and only player one has buttons attached.
+func (s *Sgb) ReadJoypad(val byte) byte {
+	if s.numPlayers == 1 {
+		return val
+	}
+
+	if s.lastJoypad == 0x30 {
+		return 0x30 | (0x0F - s.player)
+	}
+	if s.player != 0 {
+		return val | 0x0F
+	}
+
+	return val
+}
+
+func (s *Sgb) receivePacket() {
+	if len(s.packets) == 0 {
+		s.packetsNeeded = s.packet[0] & 7
+		if s.packetsNeeded == 0 {
+			return
+		}
+	}
+
+	s.packets = append(s.packets, s.packet...)
+	if byte(len(s.packets)/PACKET_SIZE) == s.packetsNeeded {
+		s.runCommand(s.packets[0]>>3, s.packets)
+		s.packets = s.packets[:0]
+	}
+}
+
+// GetColour returns the colour of a DMG shade at a position on the game screen
+func (s *Sgb) GetColour(x byte, y byte, shade byte) uint32 {
+	palette := s.attrMap[int(y/8)*20+int(x/8)]
+	return s.palettes[palette][shade&3]
+}
+
+func (s *Sgb) IsTransferPending() bool {
+	return s.pending != noTransfer
+}
+
+// Transfer receives the 4KB of tile data currently on screen, used by the *_TRN commands
+func (s *Sgb) Transfer(data []byte) {
+	switch s.pending {
+	case chrTransfer:
+		s.border.setTiles(s.chrBank, data)
+	case pctTransfer:
+		s.border.setMap(data)
+	}
+	s.pending = noTransfer
+}
+
+// RenderFrame places the game screen inside the border, returning a 256x224 buffer
+func (s *Sgb) RenderFrame(screen []uint32) []uint32 {
+	switch s.mask {
+	case MASK_CANCEL:
+		copy(s.frozen, screen)
+	case MASK_BLACK:
+		for i := range s.frozen {
+			s.frozen[i] = 0x000000FF
+		}
+	case MASK_COLOUR_0:
+		for i := range s.frozen {
+			s.frozen[i] = s.palettes[0][0]
+		}
+	}
+
+	s.border.render(s.frame, s.palettes[0][0])
+
+	for y := 0; y < 144; y++ {
+		start := (y+SCREEN_Y)*FRAME_WIDTH + SCREEN_X
+		copy(s.frame[start:start+SCREEN_WIDTH], s.frozen[y*SCREEN_WIDTH:(y+1)*SCREEN_WIDTH])
+	}
+
+	return s.frame
+}